@@ -0,0 +1,227 @@
+// Package barcode parses the codes read by the scanners into typed scans.
+//
+// Codes have the form car-CMD-arg, for example 12-CL-A for the clue A
+// sticker of car 12, 12-EM-3 for emergency 3 of car 12, 12-CA-0 for the car
+// itself, 12-LOCK-0 to lock car 12, 0-UNDO-0 to undo the scanner's last
+// action or 0-SAVE-0 for the save command. 0-SAVE-2 also writes export
+// format 2 of the event.
+package barcode
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// Barcode commands
+const (
+	CmdQuit      = "QUIT"
+	CmdClear     = "CLEAR"
+	CmdSave      = "SAVE"
+	CmdStatus    = "STATUS"
+	CmdClue      = "CL"
+	CmdEmergency = "EM"
+	CmdCar       = "CA"
+	CmdLock      = "LOCK"
+	CmdUndo      = "UNDO"
+)
+
+// Kind is the type of a scan.
+type Kind int
+
+const (
+	KindClue Kind = iota + 1
+	KindEmergency
+	KindCar
+	KindQuit
+	KindClear
+	KindSave
+	KindStatus
+	KindLock
+	KindUndo
+)
+
+var kindCommands = map[Kind]string{
+	KindClue:      CmdClue,
+	KindEmergency: CmdEmergency,
+	KindCar:       CmdCar,
+	KindQuit:      CmdQuit,
+	KindClear:     CmdClear,
+	KindSave:      CmdSave,
+	KindStatus:    CmdStatus,
+	KindLock:      CmdLock,
+	KindUndo:      CmdUndo,
+}
+
+// Command returns the barcode command for the kind, for example "CL".
+func (k Kind) Command() string {
+	return kindCommands[k]
+}
+
+func (k Kind) String() string {
+	if cmd, ok := kindCommands[k]; ok {
+		return cmd
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// MarshalText writes the kind as its barcode command.
+func (k Kind) MarshalText() ([]byte, error) {
+	if cmd, ok := kindCommands[k]; ok {
+		return []byte(cmd), nil
+	}
+	return nil, fmt.Errorf("unknown barcode kind %d", int(k))
+}
+
+// UnmarshalText reads a kind written by MarshalText.
+func (k *Kind) UnmarshalText(text []byte) error {
+	kind, ok := parseKind(string(text))
+	if !ok {
+		return fmt.Errorf("unknown barcode command %q", text)
+	}
+	*k = kind
+	return nil
+}
+
+// IsSticker reports whether the kind is a clue or emergency sticker.
+func (k Kind) IsSticker() bool {
+	return k == KindClue || k == KindEmergency
+}
+
+// Scan is a parsed barcode.
+type Scan struct {
+	// Code is the barcode as read.
+	Code string
+	// Car is the car number. It is 0 for commands that apply to every car.
+	Car  int
+	Kind Kind
+	// Index is the clue number (1 is A), the emergency number or the
+	// export format number of a SAVE. It is 0 for other kinds.
+	Index int
+}
+
+// Clue returns the letter of a clue scan, for example "A".
+func (s Scan) Clue() string {
+	if s.Kind != KindClue {
+		return ""
+	}
+	return string(rune('A' + s.Index - 1))
+}
+
+// String returns the scan in the car-CMD-arg form.
+func (s Scan) String() string {
+	switch s.Kind {
+	case KindClue:
+		return fmt.Sprintf("%v-%v-%v", s.Car, CmdClue, s.Clue())
+	case KindEmergency, KindSave:
+		return fmt.Sprintf("%v-%v-%v", s.Car, s.Kind.Command(), s.Index)
+	}
+	return fmt.Sprintf("%v-%v-0", s.Car, s.Kind.Command())
+}
+
+// Limits are the car, clue and emergency numbers valid for an event.
+type Limits struct {
+	Cars        int
+	Clues       int
+	Emergencies int
+}
+
+// Errors wrapped by ParseError
+var (
+	ErrFormat    = errors.New("not a recognised barcode")
+	ErrCar       = errors.New("invalid car number")
+	ErrCommand   = errors.New("unknown command")
+	ErrClue      = errors.New("invalid clue")
+	ErrEmergency = errors.New("invalid emergency")
+)
+
+// ParseError describes a code that could not be parsed.
+type ParseError struct {
+	Code   string
+	Err    error
+	Detail string
+}
+
+func (e *ParseError) Error() string {
+	if len(e.Detail) == 0 {
+		return fmt.Sprintf("barcode %q: %v", e.Code, e.Err)
+	}
+	return fmt.Sprintf("barcode %q: %v: %v", e.Code, e.Err, e.Detail)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+func parseError(code string, err error, format string, a ...interface{}) error {
+	return &ParseError{Code: code, Err: err, Detail: fmt.Sprintf(format, a...)}
+}
+
+// Parse parses a barcode in the car-CMD-arg form and checks it against the
+// event limits. Clue letters may be upper or lower case. The returned error
+// is a *ParseError.
+func Parse(code string, limits Limits) (Scan, error) {
+	return defaultGrammar.Parse(code, limits)
+}
+
+// parseKind returns the kind for a barcode command.
+func parseKind(cmd string) (Kind, bool) {
+	for kind, kindCmd := range kindCommands {
+		if kindCmd == cmd {
+			return kind, true
+		}
+	}
+	return 0, false
+}
+
+// Validate checks the car and index of a scan against the event limits.
+// Stickers, CA and LOCK codes need a car from 1 to limits.Cars; other
+// commands may also use car 0.
+func (s Scan) Validate(limits Limits) error {
+	minCar := 1
+	if !s.Kind.IsSticker() && s.Kind != KindCar && s.Kind != KindLock {
+		minCar = 0
+	}
+	if s.Car < minCar || s.Car > limits.Cars {
+		return parseError(s.Code, ErrCar, "car %v is not between %v and %v", s.Car, minCar, limits.Cars)
+	}
+	switch s.Kind {
+	case KindClue:
+		if s.Index < 1 || s.Index > limits.Clues {
+			return parseError(s.Code, ErrClue, "clue %v is not between 1 (A) and %v (%v)", s.Index, limits.Clues, string(rune('A'+limits.Clues-1)))
+		}
+	case KindEmergency:
+		if s.Index < 1 || s.Index > limits.Emergencies {
+			return parseError(s.Code, ErrEmergency, "emergency %v is not between 1 and %v", s.Index, limits.Emergencies)
+		}
+	}
+	return nil
+}
+
+// parseNumber parses a non-negative decimal number made only of digits.
+func parseNumber(s string) (int, error) {
+	if len(s) == 0 || len(s) > 9 {
+		return 0, strconv.ErrSyntax
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return 0, strconv.ErrSyntax
+		}
+	}
+	return strconv.Atoi(s)
+}
+
+// parseClue returns the clue number of a single letter, where A is 1.
+func parseClue(s string) (int, error) {
+	if len(s) != 1 {
+		return 0, strconv.ErrSyntax
+	}
+	r := s[0]
+	if r >= 'a' && r <= 'z' {
+		r = r - 'a' + 'A'
+	}
+	if r < 'A' || r > 'Z' {
+		return 0, strconv.ErrSyntax
+	}
+	return int(r-'A') + 1, nil
+}
@@ -0,0 +1,164 @@
+package barcode
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/quick"
+)
+
+var testLimits = Limits{Cars: 99, Clues: 26, Emergencies: 26}
+
+func TestParse(t *testing.T) {
+	long := strings.Repeat("9", 40)
+	tests := []struct {
+		code string
+		want Scan
+		err  error
+	}{
+		{code: "12-CL-A", want: Scan{Car: 12, Kind: KindClue, Index: 1}},
+		{code: "12-CL-a", want: Scan{Car: 12, Kind: KindClue, Index: 1}},
+		{code: "012-CL-z", want: Scan{Car: 12, Kind: KindClue, Index: 26}},
+		{code: "12-EM-3", want: Scan{Car: 12, Kind: KindEmergency, Index: 3}},
+		{code: "12-CA-0", want: Scan{Car: 12, Kind: KindCar}},
+		{code: "0-SAVE-2", want: Scan{Kind: KindSave, Index: 2}},
+		{code: "0-SAVE-x", want: Scan{Kind: KindSave}},
+		{code: "0-UNDO-0", want: Scan{Kind: KindUndo}},
+		{code: "12-EM-99", err: ErrEmergency},
+		{code: "12-EM-0", err: ErrEmergency},
+		{code: "12-EM--1", err: ErrFormat},
+		{code: "-3-CL-A", err: ErrFormat},
+		{code: "12-CL-[", err: ErrClue},
+		{code: "12-CL-@", err: ErrClue},
+		{code: "12-CL-AB", err: ErrClue},
+		{code: "12-CL-", err: ErrClue},
+		{code: "x-CL-A", err: ErrCar},
+		{code: "-CL-A", err: ErrCar},
+		{code: "0-CL-A", err: ErrCar},
+		{code: "100-CL-A", err: ErrCar},
+		{code: "0-LOCK-0", err: ErrCar},
+		{code: "12-XX-0", err: ErrCommand},
+		{code: "", err: ErrFormat},
+		{code: "--", err: ErrCar},
+		{code: long + "-CL-A", err: ErrCar},
+		{code: "12-EM-" + long, err: ErrEmergency},
+		{code: "12-CL-" + long, err: ErrClue},
+		{code: "0-SAVE-" + long, want: Scan{Kind: KindSave}},
+	}
+	for _, tt := range tests {
+		scan, err := Parse(tt.code, testLimits)
+		if tt.err != nil {
+			var parseErr *ParseError
+			if !errors.As(err, &parseErr) || !errors.Is(err, tt.err) {
+				t.Errorf("Parse(%q) = %v, %v; want a %v error", tt.code, scan, err, tt.err)
+			}
+			continue
+		}
+		tt.want.Code = tt.code
+		if err != nil || scan != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", tt.code, scan, err, tt.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	limits := Limits{Cars: 20, Clues: 20, Emergencies: 10}
+	for _, code := range []string{"21-CL-A", "5-CL-U", "5-EM-11"} {
+		if scan, err := Parse(code, limits); err == nil {
+			t.Errorf("Parse(%q) = %v; want an error", code, scan)
+		}
+	}
+	for _, code := range []string{"20-CL-A", "5-CL-T", "5-EM-10"} {
+		if _, err := Parse(code, limits); err != nil {
+			t.Errorf("Parse(%q): %v", code, err)
+		}
+	}
+}
+
+func TestGrammarParse(t *testing.T) {
+	g, err := NewGrammar([]Pattern{
+		{
+			Name:      "2023 stickers",
+			Match:     `TH23-(?P<car>\d{3})-(?P<kind>[CE])-(?P<index>\w+)`,
+			Kinds:     map[string]string{"C": CmdClue, "E": CmdEmergency},
+			ClueIndex: ClueNumber,
+		},
+		{Name: "save", Match: `SAVE`, Kind: CmdSave},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		code string
+		want Scan
+		err  error
+	}{
+		{code: "TH23-012-C-03", want: Scan{Car: 12, Kind: KindClue, Index: 3}},
+		{code: "TH23-012-E-26", want: Scan{Car: 12, Kind: KindEmergency, Index: 26}},
+		{code: "SAVE", want: Scan{Kind: KindSave}},
+		{code: "TH23-012-C-27", err: ErrClue},
+		{code: "TH23-012-C-A", err: ErrClue},
+		{code: "TH23-012-E-99", err: ErrEmergency},
+		{code: "TH23-000-C-01", err: ErrCar},
+		{code: "TH23-012-C-" + strings.Repeat("1", 40), err: ErrClue},
+		{code: "12-CL-A", err: ErrFormat},
+		{code: "", err: ErrFormat},
+	}
+	for _, tt := range tests {
+		scan, err := g.Parse(tt.code, testLimits)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Errorf("Parse(%q) = %v, %v; want a %v error", tt.code, scan, err, tt.err)
+			}
+			continue
+		}
+		tt.want.Code = tt.code
+		if err != nil || scan != tt.want {
+			t.Errorf("Parse(%q) = %+v, %v; want %+v", tt.code, scan, err, tt.want)
+		}
+	}
+}
+
+// checkParse parses code and checks that it either fails with a
+// *ParseError or returns a scan inside the limits.
+func checkParse(t *testing.T, g *Grammar, code string) bool {
+	scan, err := g.Parse(code, testLimits)
+	if err != nil {
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Errorf("Parse(%q) error %v is not a *ParseError", code, err)
+			return false
+		}
+		return true
+	}
+	if err := scan.Validate(testLimits); err != nil {
+		t.Errorf("Parse(%q) = %+v outside the limits: %v", code, scan, err)
+		return false
+	}
+	return true
+}
+
+func TestParseRandom(t *testing.T) {
+	g := DefaultGrammar()
+	if err := quick.Check(func(code string) bool {
+		return checkParse(t, g, code)
+	}, nil); err != nil {
+		t.Error(err)
+	}
+
+	// codes built from the pieces of real codes reach further into the
+	// parser than random strings
+	pieces := []string{"-", "-", "0", "1", "9", "12", "99", "100", "99999999999", "A", "a", "Z", "[", "@", "`", "{",
+		"CL", "EM", "CA", "LOCK", "UNDO", "SAVE", "STATUS", "CLEAR", "QUIT", " ", "\x00", "é"}
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		var b strings.Builder
+		for n := r.Intn(8); n >= 0; n-- {
+			b.WriteString(pieces[r.Intn(len(pieces))])
+		}
+		if !checkParse(t, g, b.String()) {
+			return
+		}
+	}
+}
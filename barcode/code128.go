@@ -0,0 +1,60 @@
+package barcode
+
+import "fmt"
+
+// code128 holds the Code 128 pattern of each symbol value: six elements,
+// alternately bar and space and starting with a bar, given as widths in
+// modules. Values 103 to 105 are the start codes.
+var code128 = [106]string{
+	"212222", "222122", "222221", "121223", "121322", "131222", "122213", "122312", "132212", "221213",
+	"221312", "231212", "112232", "122132", "122231", "113222", "123122", "123221", "223211", "221132",
+	"221231", "213212", "223112", "312131", "311222", "321122", "321221", "312212", "322112", "322211",
+	"212123", "212321", "232121", "111323", "131123", "131321", "112313", "132113", "132311", "211313",
+	"231113", "231311", "112133", "112331", "132131", "113123", "113321", "133121", "313121", "211331",
+	"231131", "213113", "213311", "213131", "311123", "311321", "331121", "312113", "312311", "332111",
+	"314111", "221411", "431111", "111224", "111422", "121124", "121421", "141122", "141221", "112214",
+	"112412", "122114", "122411", "142112", "142211", "241211", "221114", "413111", "241112", "134111",
+	"111242", "121142", "121241", "114212", "124112", "124211", "411212", "421112", "421211", "212141",
+	"214121", "412121", "111143", "111341", "131141", "114113", "114311", "411113", "411311", "113141",
+	"114131", "311141", "411131", "211412", "211214", "211232",
+}
+
+// Code 128 start code for code set B and the stop pattern, which has a
+// seventh element.
+const (
+	code128StartB = 104
+	code128Stop   = "2331112"
+)
+
+// Code128 returns text as a Code 128 barcode in code set B, with the start
+// code, check symbol and stop pattern added. Each element of the result is
+// one module, true for a bar and false for a space. Code set B has the
+// printable ASCII characters.
+func Code128(text string) ([]bool, error) {
+	values := []int{code128StartB}
+	for _, r := range text {
+		if r < ' ' || r > '~' {
+			return nil, fmt.Errorf("cannot encode %q in Code 128", r)
+		}
+		values = append(values, int(r-' '))
+	}
+	check := values[0]
+	for i, v := range values[1:] {
+		check += (i + 1) * v
+	}
+	values = append(values, check%103)
+
+	var modules []bool
+	add := func(pattern string) {
+		for j, width := range pattern {
+			for k := '0'; k < width; k++ {
+				modules = append(modules, j%2 == 0)
+			}
+		}
+	}
+	for _, v := range values {
+		add(code128[v])
+	}
+	add(code128Stop)
+	return modules, nil
+}
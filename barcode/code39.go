@@ -0,0 +1,57 @@
+package barcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// code39 holds the Code 39 pattern of each character: nine elements,
+// alternately bar and space and starting with a bar, each narrow (n) or
+// wide (w).
+var code39 = map[rune]string{
+	'0': "nnnwwnwnn", '1': "wnnwnnnnw", '2': "nnwwnnnnw", '3': "wnwwnnnnn",
+	'4': "nnnwwnnnw", '5': "wnnwwnnnn", '6': "nnwwwnnnn", '7': "nnnwnnwnw",
+	'8': "wnnwnnwnn", '9': "nnwwnnwnn", 'A': "wnnnnwnnw", 'B': "nnwnnwnnw",
+	'C': "wnwnnwnnn", 'D': "nnnnwwnnw", 'E': "wnnnwwnnn", 'F': "nnwnwwnnn",
+	'G': "nnnnnwwnw", 'H': "wnnnnwwnn", 'I': "nnwnnwwnn", 'J': "nnnnwwwnn",
+	'K': "wnnnnnnww", 'L': "nnwnnnnww", 'M': "wnwnnnnwn", 'N': "nnnnwnnww",
+	'O': "wnnnwnnwn", 'P': "nnwnwnnwn", 'Q': "nnnnnnwww", 'R': "wnnnnnwwn",
+	'S': "nnwnnnwwn", 'T': "nnnnwnwwn", 'U': "wwnnnnnnw", 'V': "nwwnnnnnw",
+	'W': "wwwnnnnnn", 'X': "nwnnwnnnw", 'Y': "wwnnwnnnn", 'Z': "nwwnwnnnn",
+	'-': "nwnnnnwnw", '.': "wwnnnnwnn", ' ': "nwwnnnwnn", '$': "nwnwnwnnn",
+	'/': "nwnwnnnwn", '+': "nwnnnwnwn", '%': "nnnwnwnwn", '*': "nwnnwnwnn",
+}
+
+// code39Wide is the width of a wide Code 39 element in narrow modules.
+const code39Wide = 3
+
+// Code39 returns text as a Code 39 barcode with the * start and stop
+// characters added. Each element of the result is one narrow module, true
+// for a bar and false for a space. Code 39 only has upper case letters,
+// digits, space and - . $ / + %.
+func Code39(text string) ([]bool, error) {
+	if strings.ContainsRune(text, '*') {
+		return nil, fmt.Errorf("cannot encode '*' in Code 39")
+	}
+	var modules []bool
+	for i, r := range "*" + text + "*" {
+		pattern, ok := code39[r]
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %q in Code 39", r)
+		}
+		if i > 0 {
+			// narrow gap between characters
+			modules = append(modules, false)
+		}
+		for j, width := range pattern {
+			n := 1
+			if width == 'w' {
+				n = code39Wide
+			}
+			for k := 0; k < n; k++ {
+				modules = append(modules, j%2 == 0)
+			}
+		}
+	}
+	return modules, nil
+}
@@ -0,0 +1,243 @@
+package barcode
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// How the index group of a clue is read
+const (
+	ClueLetter = "letter" // A to Z, either case
+	ClueNumber = "number" // 1 to 26
+)
+
+// Pattern describes one barcode layout. Match is a regular expression that
+// must match the whole code. Its named groups give the parts of the scan:
+//
+//	car    the car number, decimal with optional leading zeros
+//	kind   the command, or a code looked up in Kinds
+//	index  the clue letter or number, or the emergency number
+//
+// For example, stickers printed as TH23-012-C-A and TH23-012-E-03 for car
+// 12 of the 2023 hunt could be read with
+//
+//	{
+//	    "Name": "2023 stickers",
+//	    "Match": "TH23-(?P<car>\\d{3})-(?P<kind>[CE])-(?P<index>\\w+)",
+//	    "Kinds": {"C": "CL", "E": "EM"}
+//	}
+type Pattern struct {
+	// Name identifies the pattern in error messages.
+	Name  string
+	Match string
+	// Kind is the command for every code matched, for example "SAVE".
+	// When it is empty the command is read from the kind group.
+	Kind string
+	// Kinds maps the text of the kind group to a command. Without it the
+	// kind group must hold the command itself.
+	Kinds map[string]string
+	// Car is the car number for patterns without a car group.
+	Car int
+	// ClueIndex is ClueLetter (the default) or ClueNumber.
+	ClueIndex string
+}
+
+// DefaultPatterns returns the car-CMD-arg layout used on the Arizona
+// Treasure Hunt sticker cards and command sheets.
+func DefaultPatterns() []Pattern {
+	return []Pattern{
+		{
+			Name:  "car-CMD-arg",
+			Match: `(?P<car>[^-]*)-(?P<kind>[^-]*)-(?P<index>[^-]*)`,
+		},
+	}
+}
+
+type compiledPattern struct {
+	Pattern
+	re *regexp.Regexp
+}
+
+// Grammar parses barcodes using a list of patterns. The first pattern that
+// matches a code is used.
+type Grammar struct {
+	patterns []compiledPattern
+}
+
+var defaultGrammar = mustGrammar(DefaultPatterns())
+
+func mustGrammar(patterns []Pattern) *Grammar {
+	g, err := NewGrammar(patterns)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// DefaultGrammar returns the grammar for DefaultPatterns.
+func DefaultGrammar() *Grammar {
+	return defaultGrammar
+}
+
+// NewGrammar compiles a list of patterns.
+func NewGrammar(patterns []Pattern) (*Grammar, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no barcode patterns")
+	}
+	g := &Grammar{}
+	for i, p := range patterns {
+		name := p.Name
+		if len(name) == 0 {
+			name = fmt.Sprintf("pattern %v", i+1)
+			p.Name = name
+		}
+		re, err := regexp.Compile("^(?:" + p.Match + ")$")
+		if err != nil {
+			return nil, fmt.Errorf("barcode %v: %v", name, err)
+		}
+		groups := make(map[string]bool)
+		for _, group := range re.SubexpNames() {
+			groups[group] = true
+		}
+		for group := range groups {
+			switch group {
+			case "", "car", "kind", "index":
+			default:
+				return nil, fmt.Errorf("barcode %v: unknown group %q", name, group)
+			}
+		}
+		if len(p.Kind) > 0 {
+			if _, ok := parseKind(p.Kind); !ok {
+				return nil, fmt.Errorf("barcode %v: unknown command %q", name, p.Kind)
+			}
+		} else if !groups["kind"] {
+			return nil, fmt.Errorf("barcode %v: needs a Kind or a kind group", name)
+		}
+		for text, cmd := range p.Kinds {
+			if _, ok := parseKind(cmd); !ok {
+				return nil, fmt.Errorf("barcode %v: unknown command %q for %q", name, cmd, text)
+			}
+		}
+		switch p.ClueIndex {
+		case "":
+			p.ClueIndex = ClueLetter
+		case ClueLetter, ClueNumber:
+		default:
+			return nil, fmt.Errorf("barcode %v: ClueIndex must be %q or %q", name, ClueLetter, ClueNumber)
+		}
+		g.patterns = append(g.patterns, compiledPattern{Pattern: p, re: re})
+	}
+	return g, nil
+}
+
+// Parse parses a barcode with the first pattern that matches it and checks
+// the scan against the event limits. The returned error is a *ParseError.
+func (g *Grammar) Parse(code string, limits Limits) (Scan, error) {
+	for _, p := range g.patterns {
+		match := p.re.FindStringSubmatch(code)
+		if match == nil {
+			continue
+		}
+		scan, err := p.scan(code, match)
+		if err != nil {
+			return Scan{}, err
+		}
+		if err := scan.Validate(limits); err != nil {
+			return Scan{}, err
+		}
+		return scan, nil
+	}
+	if len(g.patterns) == 1 {
+		return Scan{}, parseError(code, ErrFormat, "expected %v", g.patterns[0].Name)
+	}
+	return Scan{}, parseError(code, ErrFormat, "matches none of the %v barcode patterns", len(g.patterns))
+}
+
+// Code returns scan in the car-CMD-arg form for printing, after checking
+// that the grammar reads it back as the same scan, as grammars with other
+// layouts may not.
+func (g *Grammar) Code(scan Scan, limits Limits) (string, error) {
+	code := scan.String()
+	got, err := g.Parse(code, limits)
+	if err != nil {
+		return "", fmt.Errorf("the barcode layouts cannot read %v: %v", code, err)
+	}
+	if got.Kind != scan.Kind || got.Car != scan.Car || got.Index != scan.Index && scan.Kind != KindCar {
+		return "", fmt.Errorf("the barcode layouts read %v as %v", code, got)
+	}
+	return code, nil
+}
+
+// scan builds a scan from the groups matched by the pattern.
+func (p compiledPattern) scan(code string, match []string) (Scan, error) {
+	groups := make(map[string]string)
+	hasGroup := make(map[string]bool)
+	for i, name := range p.re.SubexpNames() {
+		if len(name) > 0 {
+			groups[name] = match[i]
+			hasGroup[name] = true
+		}
+	}
+
+	scan := Scan{Code: code, Car: p.Car}
+	if hasGroup["car"] {
+		car, err := parseNumber(groups["car"])
+		if err != nil {
+			return Scan{}, parseError(code, ErrCar, "%q is not a number", groups["car"])
+		}
+		scan.Car = car
+	}
+
+	cmd := p.Kind
+	if len(cmd) == 0 {
+		cmd = groups["kind"]
+		if p.Kinds != nil {
+			kindCmd, ok := p.Kinds[cmd]
+			if !ok {
+				return Scan{}, parseError(code, ErrCommand, "%q", cmd)
+			}
+			cmd = kindCmd
+		}
+	}
+	kind, ok := parseKind(cmd)
+	if !ok {
+		return Scan{}, parseError(code, ErrCommand, "%q", cmd)
+	}
+	scan.Kind = kind
+
+	index := groups["index"]
+	var err error
+	switch kind {
+	case KindClue:
+		if p.ClueIndex == ClueNumber {
+			scan.Index, err = parseNumber(index)
+			if err != nil {
+				return Scan{}, parseError(code, ErrClue, "%q is not a number", index)
+			}
+		} else {
+			scan.Index, err = parseClue(index)
+			if err != nil {
+				return Scan{}, parseError(code, ErrClue, "%q is not a letter", index)
+			}
+		}
+	case KindEmergency:
+		scan.Index, err = parseNumber(index)
+		if err != nil {
+			return Scan{}, parseError(code, ErrEmergency, "%q is not a number", index)
+		}
+	case KindSave:
+		// an optional export format number, anything else is 0
+		scan.Index, _ = parseNumber(index)
+	}
+	return scan, nil
+}
+
+// String lists the pattern names.
+func (g *Grammar) String() string {
+	names := make([]string, 0, len(g.patterns))
+	for _, p := range g.patterns {
+		names = append(names, p.Name)
+	}
+	return strings.Join(names, ", ")
+}
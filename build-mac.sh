@@ -1,4 +1,5 @@
 # GOOS=darwin go build -o thcount thcount.go
 
-GOOS=darwin go1.16.15 build -o thcount thcount.go
-zip thcount-mac.zip thcount templates/*
\ No newline at end of file
+GOOS=darwin go1.16.15 build -o thcount .
+GOOS=darwin go1.16.15 build -o thcount-cli ./cmd/thcount-cli
+zip thcount-mac.zip thcount thcount-cli templates/*
\ No newline at end of file
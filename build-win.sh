@@ -1,3 +1,4 @@
-GOOS=windows GOARCH=amd64 go build -o thcount.exe thcount.go
+GOOS=windows GOARCH=amd64 go build -o thcount.exe .
+GOOS=windows GOARCH=amd64 go build -o thcount-cli.exe ./cmd/thcount-cli
 
-zip thcount-win.zip thcount.exe templates/*
\ No newline at end of file
+zip thcount-win.zip thcount.exe thcount-cli.exe templates/*
\ No newline at end of file
@@ -0,0 +1,359 @@
+// Package client is a Go client for the JSON API of the barcode counting
+// station.
+//
+//	c := client.New("http://192.168.1.20:8080")
+//	cars, err := c.Cars(ctx)
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultURL is the address of a counting station running on this computer.
+const DefaultURL = "http://localhost:8080"
+
+// CarData is the counted data for a car.
+type CarData struct {
+	CarNum        int
+	Scanned       bool
+	Emergencies   int
+	Clues         int
+	EmergencyList string
+	ClueList      string
+	ScanTime      time.Time
+	Duplicates    int
+	RapidRepeats  int
+	// Status is "not-returned", "in-progress", "counted" or "locked".
+	Status string
+	Lock   CarLock
+	// Edited is set if the count has been changed by hand.
+	Edited bool
+	// Score is the car's score under the event's scoring and Rank its
+	// place among the counted cars, or 0 if it has not been counted.
+	Score int
+	Rank  int
+}
+
+// CarLock records who locked a car and when.
+type CarLock struct {
+	Locked bool
+	By     string
+	Time   time.Time
+}
+
+// Stickers lists the clue and emergency stickers that are still present on
+// a car's card. Index 0 is clue A or emergency 1.
+type Stickers struct {
+	CarNum      int
+	Clues       [26]bool
+	Emergencies [26]bool
+	// Version is the car's version when the stickers were read. SetStickers
+	// fails with status 409 if the car has changed since; set it to 0 to
+	// overwrite regardless.
+	Version int
+}
+
+// TallyData holds the clue and emergency totals for all cars.
+type TallyData struct {
+	TotalClues         int
+	CountedClues       int
+	TotalEmergencies   int
+	CountedEmergencies int
+	LastSaved          time.Time
+}
+
+// ScannerData describes a barcode scanner attached to the station.
+type ScannerData struct {
+	ScannerNum   int
+	ScanCount    int
+	LastScanTime time.Time
+}
+
+// ScanResult is the result of a scan sent with Scan.
+type ScanResult struct {
+	Code  string
+	Valid bool
+}
+
+// SaveResult is the result of a save triggered with Save.
+type SaveResult struct {
+	Saved     bool
+	LastSaved time.Time
+}
+
+// Error is an error response from the counting station.
+type Error struct {
+	Status  int
+	Message string `json:"Error"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("thcount: %v %v", e.Status, e.Message)
+}
+
+// Client talks to a single counting station.
+type Client struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// New returns a client for the counting station at baseURL, for example
+// "http://192.168.1.20:8080".
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Cars returns the counted data for every car.
+func (c *Client) Cars(ctx context.Context) ([]CarData, error) {
+	var cars []CarData
+	err := c.do(ctx, http.MethodGet, "/api/v1/cars", nil, &cars)
+	return cars, err
+}
+
+// Car returns the counted data for one car.
+func (c *Client) Car(ctx context.Context, car int) (CarData, error) {
+	var data CarData
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/cars/%v", car), nil, &data)
+	return data, err
+}
+
+// Stickers returns the stickers still present on a car's card.
+func (c *Client) Stickers(ctx context.Context, car int) (Stickers, error) {
+	var stickers Stickers
+	err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/v1/cars/%v/stickers", car), nil, &stickers)
+	return stickers, err
+}
+
+// SetStickers replaces the stickers recorded for a car and returns the
+// stored result.
+func (c *Client) SetStickers(ctx context.Context, car int, stickers Stickers) (Stickers, error) {
+	var result Stickers
+	err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/v1/cars/%v/stickers", car), stickers, &result)
+	return result, err
+}
+
+// Lock locks a car so its count cannot be changed. by names the scorer
+// and may be empty.
+func (c *Client) Lock(ctx context.Context, car int, by string) (CarData, error) {
+	var data CarData
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/cars/%v/lock", car), struct{ By string }{by}, &data)
+	return data, err
+}
+
+// Unlock unlocks a locked car.
+func (c *Client) Unlock(ctx context.Context, car int, by string) (CarData, error) {
+	var data CarData
+	err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/v1/cars/%v/unlock", car), struct{ By string }{by}, &data)
+	return data, err
+}
+
+// Tally returns the clue and emergency totals.
+func (c *Client) Tally(ctx context.Context) (TallyData, error) {
+	var tally TallyData
+	err := c.do(ctx, http.MethodGet, "/api/v1/tally", nil, &tally)
+	return tally, err
+}
+
+// Scanners returns the scanners that have read at least one code.
+func (c *Client) Scanners(ctx context.Context) ([]ScannerData, error) {
+	var scanners []ScannerData
+	err := c.do(ctx, http.MethodGet, "/api/v1/scanners", nil, &scanners)
+	return scanners, err
+}
+
+// Scan sends a barcode to the station as if it had been scanned.
+func (c *Client) Scan(ctx context.Context, code string) (ScanResult, error) {
+	var result ScanResult
+	err := c.do(ctx, http.MethodPost, "/api/v1/scans", struct{ Code string }{code}, &result)
+	return result, err
+}
+
+// Save saves the station's state and writes a text export on the station.
+func (c *Client) Save(ctx context.Context) (SaveResult, error) {
+	var result SaveResult
+	err := c.do(ctx, http.MethodPost, "/api/v1/save", nil, &result)
+	return result, err
+}
+
+// Export writes the text export in the Scoring Program format to w.
+func (c *Client) Export(ctx context.Context, w io.Writer) error {
+	return c.ExportFormat(ctx, "", w)
+}
+
+// ExportFormat writes the export in a format such as "csv" to w. An empty
+// format is the text export.
+func (c *Client) ExportFormat(ctx context.Context, format string, w io.Writer) error {
+	path := "/api/v1/export"
+	if len(format) > 0 {
+		path += "?format=" + url.QueryEscape(format)
+	}
+	resp, err := c.send(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+// ImportResult lists the cars set by an import and the locked cars it left
+// as they were.
+type ImportResult struct {
+	Imported []int
+	Locked   []int
+}
+
+// Import loads a text export into the station's count. source names the
+// file in the edit history.
+func (c *Client) Import(ctx context.Context, source string, r io.Reader) (ImportResult, error) {
+	var result ImportResult
+	text, err := ioutil.ReadAll(r)
+	if err != nil {
+		return result, err
+	}
+	body := struct {
+		Source string
+		Text   string
+	}{source, string(text)}
+	err = c.do(ctx, http.MethodPost, "/api/v1/import", body, &result)
+	return result, err
+}
+
+// Station is the state of another counting station to merge, with the
+// contents of its carstate.json, timestate.json and timeline.json. Times and
+// Timeline may be left empty.
+type Station struct {
+	Name     string
+	Count    json.RawMessage
+	Times    json.RawMessage `json:",omitempty"`
+	Timeline json.RawMessage `json:",omitempty"`
+}
+
+// MergeConflict is a car a merge left as it was for a scorer to resolve.
+type MergeConflict struct {
+	Car     int
+	Station string
+	Kind    string
+	Detail  string
+}
+
+// MergeResult lists the cars changed by a merge and the conflicts it found.
+type MergeResult struct {
+	Merged    []int
+	Conflicts []MergeConflict
+}
+
+// Merge adds the sticker scans of other stations to the station's count.
+func (c *Client) Merge(ctx context.Context, stations []Station) (MergeResult, error) {
+	var result MergeResult
+	body := struct {
+		Stations []Station
+	}{stations}
+	err := c.do(ctx, http.MethodPost, "/api/v1/merge", body, &result)
+	return result, err
+}
+
+// CarTime holds a car's check-out and check-in times.
+type CarTime struct {
+	CheckOut time.Time
+	CheckIn  time.Time
+}
+
+// ReplicaCar is a car's count forwarded from one station to another. Count
+// is the car's row of the sticker matrix, and Version the receiving
+// station's version of the car it is based on, or 0 if it is not known.
+type ReplicaCar struct {
+	Car      int
+	Version  int
+	Count    []bool
+	Times    CarTime
+	ScanTime time.Time
+	Edited   bool
+}
+
+// ReplicaResult lists the cars a primary station set, the locked cars it
+// left as they were and the conflicts it found. Versions maps each car
+// whose count now matches the one sent to its version on the primary.
+type ReplicaResult struct {
+	Applied   []int
+	Locked    []int
+	Versions  map[int]int
+	Conflicts []MergeConflict
+}
+
+// Replicate sends the counts of cars changed at the named station to the
+// station c talks to, which replaces its counts of those cars, or adds to
+// them the ones that have changed there since.
+func (c *Client) Replicate(ctx context.Context, station string, cars []ReplicaCar) (ReplicaResult, error) {
+	var result ReplicaResult
+	body := struct {
+		Station string
+		Cars    []ReplicaCar
+	}{station, cars}
+	err := c.do(ctx, http.MethodPost, "/api/v1/replicate", body, &result)
+	return result, err
+}
+
+// send makes a request and returns the response if it was successful.
+func (c *Client) send(ctx context.Context, method string, path string, body interface{}) (*http.Response, error) {
+	u, err := url.Parse(c.BaseURL + path)
+	if err != nil {
+		return nil, err
+	}
+	var reader io.Reader
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(buf)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	apiErr := &Error{Status: resp.StatusCode}
+	raw, _ := ioutil.ReadAll(resp.Body)
+	if json.Unmarshal(raw, apiErr) != nil || len(apiErr.Message) == 0 {
+		apiErr.Message = strings.TrimSpace(string(raw))
+	}
+	apiErr.Status = resp.StatusCode
+	return nil, apiErr
+}
+
+// do makes a JSON request and decodes the response into result.
+func (c *Client) do(ctx context.Context, method string, path string, body interface{}, result interface{}) error {
+	resp, err := c.send(ctx, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(result)
+}
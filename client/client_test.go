@@ -0,0 +1,100 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serve returns a client for a test server that handles every request with
+// handler.
+func serve(t *testing.T, handler http.HandlerFunc) *Client {
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+	return New(srv.URL + "/")
+}
+
+func TestCars(t *testing.T) {
+	c := serve(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet || req.URL.Path != "/api/v1/cars" {
+			t.Errorf("request %v %v; want GET /api/v1/cars", req.Method, req.URL.Path)
+		}
+		w.Write([]byte(`[{"CarNum": 1, "Scanned": true, "Clues": 3, "ClueList": "a-c", "Edited": true, "Score": 30, "Rank": 1},
+			{"CarNum": 2}]`))
+	})
+	cars, err := c.Cars(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cars) != 2 {
+		t.Fatalf("Cars() = %+v; want 2 cars", cars)
+	}
+	want := CarData{CarNum: 1, Scanned: true, Clues: 3, ClueList: "a-c", Edited: true, Score: 30, Rank: 1}
+	if got := cars[0]; got.CarNum != want.CarNum || got.Scanned != want.Scanned || got.Clues != want.Clues ||
+		got.ClueList != want.ClueList || got.Edited != want.Edited || got.Score != want.Score || got.Rank != want.Rank {
+		t.Errorf("Cars()[0] = %+v; want %+v", got, want)
+	}
+}
+
+func TestSetStickersConflict(t *testing.T) {
+	c := serve(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPut || req.URL.Path != "/api/v1/cars/5/stickers" {
+			t.Errorf("request %v %v; want PUT /api/v1/cars/5/stickers", req.Method, req.URL.Path)
+		}
+		var stickers Stickers
+		if err := json.NewDecoder(req.Body).Decode(&stickers); err != nil || stickers.Version != 3 {
+			t.Errorf("body = %+v, %v; want version 3", stickers, err)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		w.Write([]byte(`{"Error": "car 5: stale (version 3, now 4)"}`))
+	})
+	_, err := c.SetStickers(context.Background(), 5, Stickers{CarNum: 5, Version: 3})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("SetStickers() error = %v; want an *Error", err)
+	}
+	if apiErr.Status != http.StatusConflict || apiErr.Message != "car 5: stale (version 3, now 4)" {
+		t.Errorf("SetStickers() error = %+v; want status %v and the station's message", apiErr, http.StatusConflict)
+	}
+}
+
+func TestExportFormat(t *testing.T) {
+	c := serve(t, func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != "/api/v1/export" {
+			t.Errorf("request path %v; want /api/v1/export", req.URL.Path)
+		}
+		switch req.URL.Query().Get("format") {
+		case "":
+			w.Write([]byte("\t1\t0\ta-c\t3\n"))
+		case "csv":
+			w.Write([]byte("Car,Team\n1,Red\n"))
+		default:
+			http.Error(w, "unknown format", http.StatusNotFound)
+		}
+	})
+	ctx := context.Background()
+	for _, tt := range []struct {
+		format string
+		want   string
+	}{
+		{"", "\t1\t0\ta-c\t3\n"},
+		{"csv", "Car,Team\n1,Red\n"},
+	} {
+		var buf bytes.Buffer
+		if err := c.ExportFormat(ctx, tt.format, &buf); err != nil || buf.String() != tt.want {
+			t.Errorf("ExportFormat(%q) = %q, %v; want %q", tt.format, buf.String(), err, tt.want)
+		}
+	}
+
+	var buf bytes.Buffer
+	err := c.ExportFormat(ctx, "pdf & more", &buf)
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusNotFound || apiErr.Message != "unknown format" || buf.Len() != 0 {
+		t.Errorf("ExportFormat of an unknown format = %q, %v; want a %v *Error", buf.String(), err, http.StatusNotFound)
+	}
+}
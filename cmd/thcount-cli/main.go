@@ -0,0 +1,304 @@
+// Command thcount-cli reads and updates a running counting station through
+// its JSON API.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/awoodward/azth-scoringcount/client"
+)
+
+const (
+	usage = `usage: %s [options] command [args]
+
+Commands:
+  cars               list every car
+  car N              show car N
+  stickers N         show the stickers still on car N's card
+  tally              show clue and emergency totals
+  scanners           list the scanners in use
+  scan CODE...       send one or more barcodes, e.g. 12-CL-A
+  save               save the station's data
+  export [FILE]      write the text export to FILE or standard output, or
+                     the CSV or XLSX export if FILE ends in .csv or .xlsx
+  import FILE        replace the counts of the cars in a text export
+  merge DIR...       merge the counts of other stations from directories
+                     holding their carstate.json, timestate.json and
+                     timeline.json
+  lock N [NAME]      lock car N so its count cannot be changed
+  unlock N [NAME]    unlock car N
+
+Options:
+`
+)
+
+const timeFormat = "Jan 02, 2006 15:04:05"
+
+// errUsage is returned by run when the command line is not understood.
+var errUsage = errors.New("usage")
+
+func main() {
+	server := flag.String("server", client.DefaultURL, "Counting station address")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), usage, os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	log.SetFlags(0)
+
+	err := run(client.New(*server), flag.Args())
+	if errors.Is(err, errUsage) {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// run runs the command in args against the station c talks to.
+func run(c *client.Client, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	ctx := context.Background()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	defer tw.Flush()
+
+	switch args[0] {
+	case "cars":
+		cars, err := c.Cars(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tw, "Car\tScanned\tClues\tEmergencies\tClue List\tEmergency List")
+		for _, car := range cars {
+			fmt.Fprintf(tw, "%v\t%v\t%v\t%v\t%v\t%v\n", car.CarNum, car.Scanned, car.Clues, car.Emergencies, car.ClueList, car.EmergencyList)
+		}
+	case "car":
+		num, err := carArg(args)
+		if err != nil {
+			return err
+		}
+		car, err := c.Car(ctx, num)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "Car:\t%v\n", car.CarNum)
+		fmt.Fprintf(tw, "Scanned:\t%v\n", car.Scanned)
+		fmt.Fprintf(tw, "Status:\t%v\n", car.Status)
+		if car.Lock.Locked {
+			fmt.Fprintf(tw, "Locked by:\t%v at %v\n", car.Lock.By, car.Lock.Time.Format(timeFormat))
+		}
+		fmt.Fprintf(tw, "Clues visited (%v):\t%v\n", car.Clues, car.ClueList)
+		fmt.Fprintf(tw, "Emergencies opened (%v):\t%v\n", car.Emergencies, car.EmergencyList)
+		fmt.Fprintf(tw, "Last scan:\t%v\n", car.ScanTime.Format(timeFormat))
+	case "stickers":
+		car, err := carArg(args)
+		if err != nil {
+			return err
+		}
+		stickers, err := c.Stickers(ctx, car)
+		if err != nil {
+			return err
+		}
+		clues := make([]string, 0)
+		emergencies := make([]string, 0)
+		for i := range stickers.Clues {
+			if stickers.Clues[i] {
+				clues = append(clues, string(rune('A'+i)))
+			}
+			if stickers.Emergencies[i] {
+				emergencies = append(emergencies, strconv.Itoa(i+1))
+			}
+		}
+		fmt.Fprintf(tw, "Clue stickers:\t%v\n", strings.Join(clues, " "))
+		fmt.Fprintf(tw, "Emergency stickers:\t%v\n", strings.Join(emergencies, " "))
+	case "tally":
+		tally, err := c.Tally(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "Total Clues:\t%v\n", tally.TotalClues)
+		fmt.Fprintf(tw, "Counted Clues:\t%v\n", tally.CountedClues)
+		fmt.Fprintf(tw, "Total Emergencies:\t%v\n", tally.TotalEmergencies)
+		fmt.Fprintf(tw, "Counted Emergencies:\t%v\n", tally.CountedEmergencies)
+		fmt.Fprintf(tw, "Last Save:\t%v\n", tally.LastSaved.Format(timeFormat))
+	case "scanners":
+		scanners, err := c.Scanners(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(tw, "Scanner\tCount\tLast Scan")
+		for _, s := range scanners {
+			fmt.Fprintf(tw, "%v\t%v\t%v\n", s.ScannerNum, s.ScanCount, s.LastScanTime.Format(timeFormat))
+		}
+	case "scan":
+		if len(args) < 2 {
+			return errors.New("scan: no code given")
+		}
+		failed := 0
+		for _, code := range args[1:] {
+			result, err := c.Scan(ctx, code)
+			if err != nil {
+				log.Printf("%v: %v", code, err)
+				failed++
+				continue
+			}
+			fmt.Fprintf(tw, "%v\tok\n", result.Code)
+		}
+		if failed > 0 {
+			return fmt.Errorf("scan: %v of %v codes failed", failed, len(args)-1)
+		}
+	case "save":
+		result, err := c.Save(ctx)
+		if err != nil {
+			return err
+		}
+		if !result.Saved {
+			fmt.Fprintln(tw, "No data to save")
+			break
+		}
+		fmt.Fprintf(tw, "Saved at %v\n", result.LastSaved.Format(timeFormat))
+	case "export":
+		if len(args) < 2 {
+			return c.Export(ctx, os.Stdout)
+		}
+		return exportFile(ctx, c, args[1])
+	case "import":
+		if len(args) < 2 {
+			return errors.New("import: no file given")
+		}
+		f, err := os.Open(args[1])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		result, err := c.Import(ctx, filepath.Base(args[1]), f)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "Imported:\t%v cars\n", len(result.Imported))
+		if len(result.Locked) > 0 {
+			fmt.Fprintf(tw, "Locked, not changed:\t%v\n", strings.Trim(fmt.Sprint(result.Locked), "[]"))
+		}
+	case "merge":
+		if len(args) < 2 {
+			return errors.New("merge: no station directory given")
+		}
+		stations := make([]client.Station, 0, len(args)-1)
+		for _, dir := range args[1:] {
+			st, err := readStation(dir)
+			if err != nil {
+				return err
+			}
+			stations = append(stations, st)
+		}
+		result, err := c.Merge(ctx, stations)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "Merged:\t%v cars\n", len(result.Merged))
+		if len(result.Conflicts) > 0 {
+			fmt.Fprintln(tw, "Car\tStation\tConflict")
+			for _, conflict := range result.Conflicts {
+				fmt.Fprintf(tw, "%v\t%v\t%v\n", conflict.Car, conflict.Station, conflict.Detail)
+			}
+		}
+	case "lock", "unlock":
+		car, err := carArg(args)
+		if err != nil {
+			return err
+		}
+		name := ""
+		if len(args) > 2 {
+			name = strings.Join(args[2:], " ")
+		}
+		lock := c.Lock
+		if args[0] == "unlock" {
+			lock = c.Unlock
+		}
+		data, err := lock(ctx, car, name)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(tw, "Car %v is %v\n", data.CarNum, data.Status)
+	default:
+		log.Printf("unknown command %q", args[0])
+		return errUsage
+	}
+	return nil
+}
+
+// exportFile writes the export to a file, in the CSV or XLSX format if its
+// name ends in .csv or .xlsx or else the text format. The file is removed if
+// it could not be written completely.
+func exportFile(ctx context.Context, c *client.Client, name string) error {
+	format := ""
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv":
+		format = "csv"
+	case ".xlsx":
+		format = "xlsx"
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	err = c.ExportFormat(ctx, format, f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(name)
+	}
+	return err
+}
+
+// carArg returns the car number given after the command.
+func carArg(args []string) (int, error) {
+	if len(args) < 2 {
+		return 0, fmt.Errorf("%v: no car number given", args[0])
+	}
+	car, err := strconv.Atoi(args[1])
+	if err != nil {
+		return 0, fmt.Errorf("%v: invalid car number %q", args[0], args[1])
+	}
+	return car, nil
+}
+
+// readStation reads the state files a station saved in dir. Only
+// carstate.json is required.
+func readStation(dir string) (client.Station, error) {
+	st := client.Station{Name: filepath.Base(filepath.Clean(dir))}
+	for _, file := range []struct {
+		name     string
+		data     *json.RawMessage
+		required bool
+	}{
+		{"carstate.json", &st.Count, true},
+		{"timestate.json", &st.Times, false},
+		{"timeline.json", &st.Timeline, false},
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, file.name))
+		if os.IsNotExist(err) && !file.required {
+			continue
+		}
+		if err != nil {
+			return st, err
+		}
+		*file.data = b
+	}
+	return st, nil
+}
@@ -0,0 +1,302 @@
+// Package config loads the event configuration: the number of cars, clues
+// and emergencies in this year's hunt.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// DefaultFile is the event configuration read at start up.
+const DefaultFile = "thcount.json"
+
+// Defaults used when there is no configuration file
+const (
+	DefaultCars        = 99
+	DefaultClues       = 26
+	DefaultEmergencies = 26
+	DefaultDebounce    = 2000
+	DefaultSuspicious  = 3
+	DefaultAlertBeep   = "\a"
+	// With these, cars rank by clues visited and then by fewest
+	// emergencies opened.
+	DefaultCluePoints      = 100
+	DefaultEmergencyPoints = -1
+)
+
+// Event is the configuration of a treasure hunt.
+type Event struct {
+	Name        string
+	Cars        int
+	Clues       int
+	Emergencies int
+	// Barcodes lists the barcode layouts used on the sticker cards and
+	// command sheets. The car-CMD-arg layout is used when it is empty.
+	Barcodes []barcode.Pattern
+	// Framing tells the scanners how to split the bytes they read into
+	// codes. PortFraming overrides it for individual serial ports, keyed
+	// by port name.
+	Framing     Framing
+	PortFraming map[string]Framing
+	// DebounceMillis is how soon a scanner can read the same sticker again
+	// before it is treated as a rapid repeat rather than a second scan.
+	DebounceMillis int
+	// SuspiciousDuplicates is the number of duplicated stickers on one card
+	// that gets the car reported as suspicious.
+	SuspiciousDuplicates int
+	// AlertBeep is written to a scanner's serial port to make it beep when
+	// it raises an alert. Leave it empty for scanners that cannot beep on
+	// command.
+	AlertBeep string
+	// CluePoints is scored for each clue visited and EmergencyPoints for
+	// each emergency opened, usually a penalty.
+	CluePoints      int
+	EmergencyPoints int
+	// Teams names the team and division of each car for the exports.
+	Teams []Team
+	// Exports are extra export formats written from text templates. They
+	// are numbered from 1 for the SAVE barcode, so 0-SAVE-2 writes the
+	// second. A format named "text" replaces the text export.
+	Exports []ExportFormat
+}
+
+// ExportFormat is an export written from a Go text/template.
+type ExportFormat struct {
+	// Name selects the format for downloads, for example "roster".
+	Name string
+	// Extension is the file extension, "txt" if it is empty.
+	Extension string
+	// Template is executed with the export data, export.TemplateData.
+	Template string
+}
+
+// Builtin export format names, which ExportFormats cannot use except to
+// replace the text export.
+const (
+	ExportText = "text"
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+)
+
+// ExportFormat returns the export format called name.
+func (ev Event) ExportFormat(name string) (ExportFormat, bool) {
+	for _, f := range ev.Exports {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return ExportFormat{}, false
+}
+
+// FileExtension returns the format's file extension.
+func (f ExportFormat) FileExtension() string {
+	if len(f.Extension) == 0 {
+		return "txt"
+	}
+	return strings.TrimPrefix(f.Extension, ".")
+}
+
+// Check reports a format that cannot be used.
+func (f ExportFormat) Check() error {
+	switch f.Name {
+	case "":
+		return fmt.Errorf("export format needs a Name")
+	case ExportCSV, ExportXLSX:
+		return fmt.Errorf("export format %q is built in", f.Name)
+	}
+	if strings.ContainsAny(f.Name, "/\\ ") || strings.ContainsAny(f.Extension, "/\\ ") {
+		return fmt.Errorf("export format %q: name and extension cannot contain spaces or slashes", f.Name)
+	}
+	if _, err := template.New(f.Name).Parse(f.Template); err != nil {
+		return fmt.Errorf("export format %q: %v", f.Name, err)
+	}
+	return nil
+}
+
+// Team is the team driving a car.
+type Team struct {
+	Car      int
+	Name     string
+	Division string
+}
+
+// Team returns the team for a car, or a Team with only Car set if it has
+// none.
+func (ev Event) Team(car int) Team {
+	for _, team := range ev.Teams {
+		if team.Car == car {
+			return team
+		}
+	}
+	return Team{Car: car}
+}
+
+// Score returns the score of a car that visited clues and opened
+// emergencies.
+func (ev Event) Score(clues int, emergencies int) int {
+	return clues*ev.CluePoints + emergencies*ev.EmergencyPoints
+}
+
+// Terminators for Framing
+const (
+	FrameLine   = "line"   // CR, LF or CRLF
+	FrameCR     = "cr"     // CR only
+	FrameLF     = "lf"     // LF only
+	FrameCRLF   = "crlf"   // CR followed by LF
+	FrameSTXETX = "stxetx" // STX before and ETX after each code
+	FrameFixed  = "fixed"  // every Length bytes, no terminator
+	FrameNone   = "none"   // only the inter-character timeout ends a code
+)
+
+// Framing describes how a scanner marks the end of each code.
+type Framing struct {
+	// Terminator is one of the Frame constants. The default is FrameLine.
+	Terminator string
+	// Length is the code length for FrameFixed.
+	Length int
+	// TimeoutMillis ends a code when no byte has arrived for this many
+	// milliseconds. 0 turns the timeout off.
+	TimeoutMillis int
+}
+
+// Check reports framing settings that cannot be used.
+func (f Framing) Check() error {
+	switch f.Terminator {
+	case "", FrameLine, FrameCR, FrameLF, FrameCRLF, FrameSTXETX:
+	case FrameFixed:
+		if f.Length < 1 {
+			return fmt.Errorf("fixed framing needs a Length")
+		}
+	case FrameNone:
+		if f.TimeoutMillis < 1 {
+			return fmt.Errorf("framing with no terminator needs a TimeoutMillis")
+		}
+	default:
+		return fmt.Errorf("unknown framing terminator %q", f.Terminator)
+	}
+	if f.TimeoutMillis < 0 {
+		return fmt.Errorf("framing TimeoutMillis must not be negative")
+	}
+	return nil
+}
+
+// PortFramingFor returns the framing for a serial port.
+func (ev Event) PortFramingFor(port string) Framing {
+	if f, ok := ev.PortFraming[port]; ok {
+		return f
+	}
+	return ev.Framing
+}
+
+// Default returns the configuration used when there is no configuration
+// file.
+func Default() Event {
+	return Event{
+		Cars:        DefaultCars,
+		Clues:       DefaultClues,
+		Emergencies: DefaultEmergencies,
+
+		DebounceMillis:       DefaultDebounce,
+		SuspiciousDuplicates: DefaultSuspicious,
+		AlertBeep:            DefaultAlertBeep,
+		CluePoints:           DefaultCluePoints,
+		EmergencyPoints:      DefaultEmergencyPoints,
+	}
+}
+
+// Load reads the event configuration from a JSON file. Settings missing from
+// the file keep their default values. A missing file gives the defaults.
+func Load(filename string) (Event, error) {
+	ev := Default()
+	byteValue, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return ev, nil
+	}
+	if err != nil {
+		return ev, err
+	}
+	if err := json.Unmarshal(byteValue, &ev); err != nil {
+		return ev, fmt.Errorf("%v: %v", filename, err)
+	}
+	if err := ev.Check(); err != nil {
+		return ev, fmt.Errorf("%v: %v", filename, err)
+	}
+	return ev, nil
+}
+
+// Check reports settings that cannot be used.
+func (ev Event) Check() error {
+	// the count holds cars 1 to 99 with up to 26 clues and 26 emergencies
+	if ev.Cars < 1 || ev.Cars > 99 {
+		return fmt.Errorf("Cars must be from 1 to 99, not %v", ev.Cars)
+	}
+	if ev.Clues < 1 || ev.Clues > 26 {
+		return fmt.Errorf("Clues must be from 1 to 26, not %v", ev.Clues)
+	}
+	if ev.Emergencies < 1 || ev.Emergencies > 26 {
+		return fmt.Errorf("Emergencies must be from 1 to 26, not %v", ev.Emergencies)
+	}
+	if ev.DebounceMillis < 0 {
+		return fmt.Errorf("DebounceMillis must not be negative")
+	}
+	if ev.SuspiciousDuplicates < 1 {
+		return fmt.Errorf("SuspiciousDuplicates must be at least 1, not %v", ev.SuspiciousDuplicates)
+	}
+	if _, err := ev.Grammar(); err != nil {
+		return err
+	}
+	if err := ev.Framing.Check(); err != nil {
+		return err
+	}
+	for port, f := range ev.PortFraming {
+		if err := f.Check(); err != nil {
+			return fmt.Errorf("%v: %v", port, err)
+		}
+	}
+	cars := make(map[int]bool)
+	for _, team := range ev.Teams {
+		if team.Car < 1 || team.Car > ev.Cars {
+			return fmt.Errorf("team %q: car must be from 1 to %v, not %v", team.Name, ev.Cars, team.Car)
+		}
+		if cars[team.Car] {
+			return fmt.Errorf("car %v has more than one team", team.Car)
+		}
+		cars[team.Car] = true
+	}
+	names := make(map[string]bool)
+	for _, f := range ev.Exports {
+		if err := f.Check(); err != nil {
+			return err
+		}
+		if names[f.Name] {
+			return fmt.Errorf("export format %q is defined more than once", f.Name)
+		}
+		names[f.Name] = true
+	}
+	return nil
+}
+
+// Grammar returns the barcode grammar for the event.
+func (ev Event) Grammar() (*barcode.Grammar, error) {
+	if len(ev.Barcodes) == 0 {
+		return barcode.DefaultGrammar(), nil
+	}
+	return barcode.NewGrammar(ev.Barcodes)
+}
+
+// Debounce returns the rapid repeat window.
+func (ev Event) Debounce() time.Duration {
+	return time.Duration(ev.DebounceMillis) * time.Millisecond
+}
+
+// Limits returns the car, clue and emergency numbers valid for the event.
+func (ev Event) Limits() barcode.Limits {
+	return barcode.Limits{Cars: ev.Cars, Clues: ev.Clues, Emergencies: ev.Emergencies}
+}
@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestCheckLimits(t *testing.T) {
+	tests := []struct {
+		name  string
+		set   func(ev *Event)
+		valid bool
+	}{
+		{"defaults", func(ev *Event) {}, true},
+		{"99 cars", func(ev *Event) { ev.Cars = 99 }, true},
+		{"100 cars", func(ev *Event) { ev.Cars = 100 }, false},
+		{"no cars", func(ev *Event) { ev.Cars = 0 }, false},
+		{"26 clues", func(ev *Event) { ev.Clues = 26 }, true},
+		{"27 clues", func(ev *Event) { ev.Clues = 27 }, false},
+		{"26 emergencies", func(ev *Event) { ev.Emergencies = 26 }, true},
+		{"27 emergencies", func(ev *Event) { ev.Emergencies = 27 }, false},
+		{"no emergencies", func(ev *Event) { ev.Emergencies = 0 }, false},
+	}
+	for _, tt := range tests {
+		ev := Default()
+		tt.set(&ev)
+		if err := ev.Check(); (err == nil) != tt.valid {
+			t.Errorf("%v: Check() = %v; want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
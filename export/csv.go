@@ -0,0 +1,94 @@
+package export
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/format"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// csvTimeFormat is the format of the times in the CSV export, which
+// spreadsheets read as a date and time.
+const csvTimeFormat = "2006-01-02 15:04:05"
+
+// CSVFileName returns the name of the CSV export file for time t.
+func CSVFileName(t time.Time) string {
+	return fileName(t, "csv")
+}
+
+// WriteCSV writes the results of every car in the event as CSV with a
+// header row. Each clue and emergency sticker has a column that is 1 if the
+// clue was visited or the emergency opened. Cars that have not been counted
+// only have their team and times.
+func WriteCSV(f io.Writer, c *state.CountData) error {
+	count := c.ExportData()
+	ev := count.Event
+	carList := count.Cars
+	times := count.Times
+
+	header := []string{"Car", "Team", "Division", "Clues", "Emergencies", "Clue List", "Emergency List"}
+	for i := 1; i <= ev.Clues; i++ {
+		header = append(header, "Clue "+format.ClueLetter(i))
+	}
+	for i := 1; i <= ev.Emergencies; i++ {
+		header = append(header, "Emergency "+strconv.Itoa(i))
+	}
+	header = append(header, "Check Out", "Check In", "Last Scan", "Score", "Rank")
+
+	w := csv.NewWriter(f)
+	if err := w.Write(header); err != nil {
+		return err
+	}
+	for car := 1; car <= ev.Cars; car++ {
+		data := carList[car]
+		team := ev.Team(car)
+		record := []string{strconv.Itoa(car), team.Name, team.Division}
+		if data.Scanned {
+			record = append(record,
+				strconv.Itoa(data.Clues),
+				strconv.Itoa(data.Emergencies),
+				data.ClueList,
+				data.EmergencyList)
+			stickers := count.Stickers[car]
+			for i := 0; i < ev.Clues; i++ {
+				record = append(record, csvFlag(!stickers.Clues[i]))
+			}
+			for i := 0; i < ev.Emergencies; i++ {
+				record = append(record, csvFlag(!stickers.Emergencies[i]))
+			}
+		} else {
+			record = append(record, make([]string, 4+ev.Clues+ev.Emergencies)...)
+		}
+		record = append(record,
+			csvTime(times[car].CheckOut),
+			csvTime(times[car].CheckIn),
+			csvTime(data.ScanTime))
+		if data.Scanned {
+			record = append(record, strconv.Itoa(data.Score), strconv.Itoa(data.Rank))
+		} else {
+			record = append(record, "", "")
+		}
+		if err := w.Write(record); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func csvFlag(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func csvTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(csvTimeFormat)
+}
@@ -0,0 +1,191 @@
+// Package export writes the count in the format required by the Scoring
+// Program spreadsheet, as CSV, as an XLSX workbook and in export formats
+// defined by templates in the event configuration, keeps the history of
+// saves and reads old text exports back.
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/format"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// Dir is the directory saved exports are written to.
+const Dir = "exports"
+
+// fileTimeFormat names export files by the time they were written, on a 24
+// hour clock so saves twelve hours apart do not share a name.
+const fileTimeFormat = "2006-01-02_15-04-05"
+
+// FileName returns the name of the text export file for time t.
+func FileName(t time.Time) string {
+	return fileName(t, "txt")
+}
+
+// fileName returns the name of an export file for time t with extension
+// ext.
+func fileName(t time.Time, ext string) string {
+	return fmt.Sprintf("%v.%v", t.Format(fileTimeFormat), ext)
+}
+
+// WriteText writes a tab separated line per car with its clue streaks and
+// emergencies. cars is indexed by car number as returned by BuildCarData.
+func WriteText(f io.Writer, carList []state.CarData) error {
+	for i := 1; i < len(carList); i++ {
+		line := fmt.Sprintf("\t%v\t0\t%v\t%v\n", i, carList[i].ClueList, carList[i].EmergencyList)
+		_, err := io.WriteString(f, line)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadText reads a text export written by WriteText back into stickers, one
+// for each counted car. Exports list every car, and a car that was never
+// counted appears with every clue visited and every emergency opened, so
+// lines like that are skipped. The lists are read for the clues and
+// emergencies of ev, so that clue streaks roll over at its last clue.
+func ReadText(r io.Reader, ev config.Event) ([]state.Stickers, error) {
+	cars := make([]state.Stickers, 0)
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimRight(scanner.Text(), "\r\n")
+		if len(strings.TrimSpace(text)) == 0 {
+			continue
+		}
+		// tab, car, 0, clues, emergencies
+		fields := strings.Split(strings.TrimPrefix(text, "\t"), "\t")
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %v: expected car, 0, clues and emergencies", line)
+		}
+		var stickers state.Stickers
+		car, err := strconv.Atoi(strings.TrimSpace(fields[0]))
+		if err != nil || !state.ValidCar(car) {
+			return nil, fmt.Errorf("line %v: invalid car %q", line, fields[0])
+		}
+		stickers.CarNum = car
+		clues, err := format.ParseClues(fields[2], ev.Clues)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		emergencies := ""
+		if len(fields) > 3 {
+			emergencies = fields[3]
+		}
+		opened, err := format.ParseEmergencies(emergencies, ev.Emergencies)
+		if err != nil {
+			return nil, fmt.Errorf("line %v: %v", line, err)
+		}
+		if !anyPresent(clues) && !anyPresent(opened) {
+			// not counted
+			continue
+		}
+		copy(stickers.Clues[:], clues)
+		copy(stickers.Emergencies[:], opened)
+		cars = append(cars, stickers)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cars, nil
+}
+
+// anyPresent reports whether any sticker in present is still on the card.
+func anyPresent(present []bool) bool {
+	for _, p := range present {
+		if p {
+			return true
+		}
+	}
+	return false
+}
+
+// Save writes the state files and, if any car has been counted, saves the
+// exports to Dir: the text export (see WriteTextExport), the CSV export, the
+// XLSX workbook, any extra formats and a snapshot of the count for the save
+// history. The files of one save share a name that no other save uses, for
+// example exports/2023-03-18_13-04-05.txt. Save returns the name of the text
+// export file, or an empty string if there was nothing to export.
+func Save(c *state.CountData, formats ...config.ExportFormat) (string, error) {
+	c.WriteState()
+	if !c.HasCars() {
+		// nothing to save
+		log.Println("No data to save")
+		return "", nil
+	}
+	if err := os.MkdirAll(Dir, 0755); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	id, err := newSaveID(now)
+	if err != nil {
+		return "", fmt.Errorf("error saving: %v", err)
+	}
+	path := func(ext string) string {
+		return filepath.Join(Dir, id+"."+ext)
+	}
+	type exportFile struct {
+		name  string
+		write func(f io.Writer) error
+	}
+	files := []exportFile{
+		{path("txt"), func(f io.Writer) error { return WriteTextExport(f, c) }},
+		{path("csv"), func(f io.Writer) error { return WriteCSV(f, c) }},
+		{path("xlsx"), func(f io.Writer) error { return WriteXLSX(f, c) }},
+	}
+	for _, format := range formats {
+		format := format
+		files = append(files, exportFile{path(format.Name + "." + format.FileExtension()), func(f io.Writer) error {
+			return WriteTemplate(f, c, format)
+		}})
+	}
+	// a failed save removes every file it wrote, so the history only lists
+	// complete saves
+	remove := func() {
+		for _, file := range files {
+			if err := os.Remove(file.name); err != nil && !os.IsNotExist(err) {
+				log.Printf("Error removing %v: %v\n", file.name, err)
+			}
+		}
+		os.Remove(path("json"))
+	}
+	for _, file := range files {
+		if err := writeFile(file.name, file.write); err != nil {
+			remove()
+			return "", fmt.Errorf("error saving %v: %v", file.name, err)
+		}
+	}
+	if err := writeSnapshot(path("json"), newSnapshot(c, id, now)); err != nil {
+		remove()
+		return "", fmt.Errorf("error saving snapshot: %v", err)
+	}
+	c.SetSaved()
+	log.Printf("Data saved to files: %v.*\n", filepath.Join(Dir, id))
+	return files[0].name, nil
+}
+
+// writeFile creates a file and writes it with write.
+func writeFile(filename string, write func(f io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
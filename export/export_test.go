@@ -0,0 +1,82 @@
+package export
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// stickers returns a car's stickers in event ev after the clues in visited,
+// as lower case letters, were visited and the emergencies in opened opened.
+func stickers(car int, ev config.Event, visited string, opened ...int) state.Stickers {
+	s := state.Stickers{CarNum: car}
+	for i := 0; i < ev.Clues; i++ {
+		s.Clues[i] = !strings.ContainsRune(visited, rune('a'+i))
+	}
+	for i := 0; i < ev.Emergencies; i++ {
+		s.Emergencies[i] = true
+	}
+	for _, n := range opened {
+		s.Emergencies[n-1] = false
+	}
+	return s
+}
+
+func TestReadText(t *testing.T) {
+	full := config.Default()
+	small := config.Default()
+	small.Clues = 20
+	small.Emergencies = 10
+	tests := []struct {
+		name string
+		text string
+		ev   config.Event
+		want []state.Stickers
+		err  bool
+	}{
+		{
+			name: "streaks",
+			text: "\t5\t0\ta-c, f\t3, 17\n",
+			ev:   full,
+			want: []state.Stickers{stickers(5, full, "abcf", 3, 17)},
+		},
+		{
+			name: "rollover",
+			text: "\t5\t0\tx-b\t\n",
+			ev:   full,
+			want: []state.Stickers{stickers(5, full, "xyzab")},
+		},
+		{
+			name: "rollover with fewer clues",
+			text: "\t5\t0\tt-b\t10\n",
+			ev:   small,
+			want: []state.Stickers{stickers(5, small, "tab", 10)},
+		},
+		{
+			name: "cars never counted are skipped",
+			text: "\t1\t0\ta-t\t1, 2, 3, 4, 5, 6, 7, 8, 9, 10\n\t2\t0\tc\t\r\n\n",
+			ev:   small,
+			want: []state.Stickers{stickers(2, small, "c")},
+		},
+		{name: "missing emergencies", text: "\t5\t0\ta\n", ev: full, want: []state.Stickers{stickers(5, full, "a")}},
+		{name: "clue past the event", text: "\t5\t0\tu\t\n", ev: small, err: true},
+		{name: "emergency past the event", text: "\t5\t0\ta\t11\n", ev: small, err: true},
+		{name: "invalid car", text: "\t0\t0\ta\t\n", ev: full, err: true},
+		{name: "short line", text: "\t5\t0\n", ev: full, err: true},
+	}
+	for _, tt := range tests {
+		cars, err := ReadText(strings.NewReader(tt.text), tt.ev)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%v: ReadText = %+v; want an error", tt.name, cars)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(cars, tt.want) {
+			t.Errorf("%v: ReadText = %+v, %v; want %+v", tt.name, cars, err, tt.want)
+		}
+	}
+}
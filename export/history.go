@@ -0,0 +1,206 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// saveIDPattern matches the names shared by the files of one save, with a
+// number added if there was already a save in the same second.
+var saveIDPattern = regexp.MustCompile(`^\d{4}-\d\d-\d\d_\d\d-\d\d-\d\d(-\d+)?$`)
+
+// SavedExport describes one save in Dir.
+type SavedExport struct {
+	// ID names the save's files, for example 2023-03-18_13-04-05.
+	ID          string
+	Time        time.Time
+	CarsCounted int
+	Tally       state.TallyData
+	// Files are the names of the files in Dir written by the save.
+	Files []string
+	// Error is set if the save's snapshot could not be read, in which case
+	// only ID, Time and Files are set.
+	Error string `json:",omitempty"`
+}
+
+// SavedCar is a counted car as it was when it was saved.
+type SavedCar struct {
+	state.CarData
+	Stickers state.Stickers
+}
+
+// Snapshot is a save with the counted cars as they were.
+type Snapshot struct {
+	SavedExport
+	Cars []SavedCar
+}
+
+// CarChange is a car that differs between two saves. Before or After is nil
+// if the car had not been counted in that save.
+type CarChange struct {
+	Car      int
+	Before   *SavedCar
+	After    *SavedCar
+	Stickers []state.StickerChange
+}
+
+// newSaveID returns a save ID for time t that no save in Dir uses. The ID is
+// reserved by creating its empty snapshot file, so two saves in the same
+// second cannot both take it; the snapshot is written over it at the end of
+// the save.
+func newSaveID(t time.Time) (string, error) {
+	base := t.Format(fileTimeFormat)
+	id := base
+	for n := 2; ; n++ {
+		if matches, _ := filepath.Glob(filepath.Join(Dir, id+".*")); len(matches) == 0 {
+			f, err := os.OpenFile(filepath.Join(Dir, id+".json"), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+			if err == nil {
+				return id, f.Close()
+			}
+			if !os.IsExist(err) {
+				return "", err
+			}
+		}
+		id = fmt.Sprintf("%v-%v", base, n)
+	}
+}
+
+// newSnapshot returns a snapshot of the counted cars.
+func newSnapshot(c *state.CountData, id string, t time.Time) Snapshot {
+	count := c.ExportData()
+	s := Snapshot{SavedExport: SavedExport{ID: id, Time: t, Tally: count.Tally}}
+	for car, data := range count.Cars {
+		if data.Scanned {
+			s.Cars = append(s.Cars, SavedCar{CarData: data, Stickers: count.Stickers[car]})
+		}
+	}
+	s.CarsCounted = len(s.Cars)
+	return s
+}
+
+func writeSnapshot(filename string, s Snapshot) error {
+	b, err := json.MarshalIndent(s, "", " ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, b, 0644)
+}
+
+// files returns the names of the files of a save.
+func files(id string) []string {
+	matches, _ := filepath.Glob(filepath.Join(Dir, id+".*"))
+	names := make([]string, 0, len(matches))
+	for _, match := range matches {
+		names = append(names, filepath.Base(match))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Saves lists the saves in Dir, most recent first. A save whose snapshot
+// cannot be read is listed with its Error set.
+func Saves() ([]SavedExport, error) {
+	entries, err := ioutil.ReadDir(Dir)
+	if os.IsNotExist(err) {
+		return []SavedExport{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	saves := make([]SavedExport, 0)
+	for _, entry := range entries {
+		id := strings.TrimSuffix(entry.Name(), ".json")
+		if id == entry.Name() || !saveIDPattern.MatchString(id) {
+			continue
+		}
+		if entry.Size() == 0 {
+			// reserved by a save that is still being written
+			continue
+		}
+		s, err := LoadSave(id)
+		if err != nil {
+			// list the save's files even though its snapshot is damaged
+			saves = append(saves, SavedExport{ID: id, Time: entry.ModTime(), Files: files(id), Error: err.Error()})
+			continue
+		}
+		saves = append(saves, s.SavedExport)
+	}
+	sort.SliceStable(saves, func(i, j int) bool {
+		return saves[i].Time.After(saves[j].Time)
+	})
+	return saves, nil
+}
+
+// LoadSave reads the snapshot of a save.
+func LoadSave(id string) (Snapshot, error) {
+	var s Snapshot
+	if !saveIDPattern.MatchString(id) {
+		return s, fmt.Errorf("invalid save %q", id)
+	}
+	b, err := ioutil.ReadFile(filepath.Join(Dir, id+".json"))
+	if os.IsNotExist(err) {
+		return s, fmt.Errorf("no save %q", id)
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(b, &s); err != nil {
+		return s, fmt.Errorf("save %v: %v", id, err)
+	}
+	s.Files = files(id)
+	return s, nil
+}
+
+// SaveFile returns the path of one of a save's files, or an error if the
+// save has no such file.
+func SaveFile(id string, name string) (string, error) {
+	if !saveIDPattern.MatchString(id) {
+		return "", fmt.Errorf("invalid save %q", id)
+	}
+	for _, file := range files(id) {
+		if file == name {
+			return filepath.Join(Dir, file), nil
+		}
+	}
+	return "", fmt.Errorf("save %v has no file %q", id, name)
+}
+
+// DiffSaves lists the cars that differ between two saves, by car number.
+func DiffSaves(from Snapshot, to Snapshot) []CarChange {
+	var before, after [state.CarMax]*SavedCar
+	for i := range from.Cars {
+		if state.ValidCar(from.Cars[i].CarNum) {
+			before[from.Cars[i].CarNum] = &from.Cars[i]
+		}
+	}
+	for i := range to.Cars {
+		if state.ValidCar(to.Cars[i].CarNum) {
+			after[to.Cars[i].CarNum] = &to.Cars[i]
+		}
+	}
+	changes := make([]CarChange, 0)
+	for car := 1; car < state.CarMax; car++ {
+		b, a := before[car], after[car]
+		if b == nil && a == nil {
+			continue
+		}
+		change := CarChange{Car: car, Before: b, After: a}
+		if b != nil && a != nil {
+			change.Stickers = state.DiffStickers(b.Stickers, a.Stickers)
+			if len(change.Stickers) == 0 {
+				continue
+			}
+		}
+		changes = append(changes, change)
+	}
+	return changes
+}
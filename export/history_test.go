@@ -0,0 +1,96 @@
+package export
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// inTempDir runs the test in a temporary directory, where Save writes the
+// state files and Dir.
+func inTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "saves")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+}
+
+func TestSaveFailure(t *testing.T) {
+	inTempDir(t)
+	c := state.New()
+	if err := c.Process("12-CL-A", 1); err != nil {
+		t.Fatal(err)
+	}
+	// the template fails after the other exports have been written
+	broken := config.ExportFormat{Name: "broken", Template: "{{.Missing}}"}
+	if _, err := Save(c, broken); err == nil {
+		t.Fatal("Save with a broken template did not fail")
+	}
+	if matches, _ := filepath.Glob(filepath.Join(Dir, "*")); len(matches) != 0 {
+		t.Errorf("failed save left %v", matches)
+	}
+
+	name, err := Save(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saves, err := Saves()
+	if err != nil || len(saves) != 1 || len(saves[0].Files) != 4 {
+		t.Errorf("Saves() after saving %v = %+v, %v; want one save with 4 files", name, saves, err)
+	}
+}
+
+func TestSavesDamagedSnapshot(t *testing.T) {
+	inTempDir(t)
+	if err := os.Mkdir(Dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name string, data string) {
+		if err := ioutil.WriteFile(filepath.Join(Dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("2023-03-18_13-04-05.json", `{"ID": "2023-03-18_13-04-05", "CarsCounted": 3}`)
+	write("2023-03-18_13-04-05.txt", "")
+	write("2023-03-18_14-00-00.json", `{"ID": `)
+	write("2023-03-18_14-00-00.txt", "")
+	// reserved by a save still being written
+	write("2023-03-18_15-00-00.json", "")
+
+	saves, err := Saves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saves) != 2 {
+		t.Fatalf("Saves() = %+v; want 2 saves", saves)
+	}
+	for _, s := range saves {
+		switch s.ID {
+		case "2023-03-18_13-04-05":
+			if s.Error != "" || s.CarsCounted != 3 {
+				t.Errorf("save %v = %+v; want 3 cars counted", s.ID, s)
+			}
+		case "2023-03-18_14-00-00":
+			if s.Error == "" || len(s.Files) != 2 {
+				t.Errorf("damaged save %v = %+v; want an error and its 2 files", s.ID, s)
+			}
+		default:
+			t.Errorf("unexpected save %+v", s)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package export
+
+import (
+	"io"
+	"text/template"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// TemplateData is the data an export format template is executed with.
+type TemplateData struct {
+	Event config.Event
+	// Time is when the export was written.
+	Time time.Time
+	// Cars has every car in the event, in car number order.
+	Cars  []TemplateCar
+	Tally state.TallyData
+}
+
+// TemplateCar is one car's results for an export format template. The
+// CarData fields, such as CarNum, ClueList, EmergencyList, Score and Rank,
+// can be used directly.
+type TemplateCar struct {
+	state.CarData
+	Team     string
+	Division string
+	CheckOut time.Time
+	CheckIn  time.Time
+	// Stickers are the stickers still on the card.
+	Stickers state.Stickers
+}
+
+// TemplateFileName returns the name of the file for an export format at
+// time t.
+func TemplateFileName(t time.Time, format config.ExportFormat) string {
+	return fileName(t, format.Name+"."+format.FileExtension())
+}
+
+// templateData returns the data for export format templates.
+func templateData(c *state.CountData) TemplateData {
+	count := c.ExportData()
+	ev := count.Event
+	data := TemplateData{Event: ev, Time: time.Now(), Tally: count.Tally}
+	for car := 1; car <= ev.Cars; car++ {
+		team := ev.Team(car)
+		data.Cars = append(data.Cars, TemplateCar{
+			CarData:  count.Cars[car],
+			Team:     team.Name,
+			Division: team.Division,
+			CheckOut: count.Times[car].CheckOut,
+			CheckIn:  count.Times[car].CheckIn,
+			Stickers: count.Stickers[car],
+		})
+	}
+	return data
+}
+
+// WriteTemplate writes an export from a format's template.
+func WriteTemplate(f io.Writer, c *state.CountData, format config.ExportFormat) error {
+	tmpl, err := template.New(format.Name).Parse(format.Template)
+	if err != nil {
+		return err
+	}
+	return tmpl.Execute(f, templateData(c))
+}
+
+// WriteTextExport writes the text export with the event's "text" export
+// format if it has one, or else with WriteText.
+func WriteTextExport(f io.Writer, c *state.CountData) error {
+	if format, ok := c.Event().ExportFormat(config.ExportText); ok {
+		return WriteTemplate(f, c, format)
+	}
+	return WriteText(f, c.BuildCarData())
+}
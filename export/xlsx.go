@@ -0,0 +1,249 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/format"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// XLSXContentType is the MIME type of the XLSX export.
+const XLSXContentType = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+// XLSXFileName returns the name of the XLSX export file for time t.
+func XLSXFileName(t time.Time) string {
+	return fileName(t, "xlsx")
+}
+
+// sheet is a worksheet with a bold header row. Cells are strings, ints,
+// float64s or times; nil and zero times are left empty.
+type sheet struct {
+	name   string
+	header []string
+	rows   [][]interface{}
+}
+
+// WriteXLSX writes a workbook with the results of every counted car, a grid
+// of the stickers on each card and statistics for each clue and emergency.
+func WriteXLSX(f io.Writer, c *state.CountData) error {
+	count := c.ExportData()
+	ev := count.Event
+	carList := count.Cars
+	times := count.Times
+
+	results := sheet{
+		name:   "Results",
+		header: []string{"Car", "Team", "Division", "Clues", "Emergencies", "Clue List", "Emergency List", "Score", "Rank", "Check Out", "Check In", "Last Scan"},
+	}
+	grid := sheet{name: "Stickers", header: []string{"Car"}}
+	for i := 1; i <= ev.Clues; i++ {
+		grid.header = append(grid.header, format.ClueLetter(i))
+	}
+	for i := 1; i <= ev.Emergencies; i++ {
+		grid.header = append(grid.header, "EM "+strconv.Itoa(i))
+	}
+	visited := make([]int, ev.Clues)
+	opened := make([]int, ev.Emergencies)
+	counted := 0
+
+	for car := 1; car <= ev.Cars; car++ {
+		data := carList[car]
+		if !data.Scanned {
+			continue
+		}
+		counted++
+		team := ev.Team(car)
+		results.rows = append(results.rows, []interface{}{
+			car, team.Name, team.Division, data.Clues, data.Emergencies, data.ClueList, data.EmergencyList,
+			data.Score, data.Rank, times[car].CheckOut, times[car].CheckIn, data.ScanTime,
+		})
+
+		stickers := count.Stickers[car]
+		row := []interface{}{car}
+		for i := 0; i < ev.Clues; i++ {
+			if !stickers.Clues[i] {
+				visited[i]++
+				row = append(row, 1)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		for i := 0; i < ev.Emergencies; i++ {
+			if !stickers.Emergencies[i] {
+				opened[i]++
+				row = append(row, 1)
+			} else {
+				row = append(row, nil)
+			}
+		}
+		grid.rows = append(grid.rows, row)
+	}
+
+	stats := sheet{
+		name:   "Clue Statistics",
+		header: []string{"Clue", "Cars Visited", "% Visited", "", "Emergency", "Cars Opened", "% Opened"},
+	}
+	for i := 0; i < ev.Clues || i < ev.Emergencies; i++ {
+		row := make([]interface{}, 7)
+		if i < ev.Clues {
+			row[0] = format.ClueLetter(i + 1)
+			row[1] = visited[i]
+			row[2] = percent(visited[i], counted)
+		}
+		if i < ev.Emergencies {
+			row[4] = i + 1
+			row[5] = opened[i]
+			row[6] = percent(opened[i], counted)
+		}
+		stats.rows = append(stats.rows, row)
+	}
+
+	return writeWorkbook(f, []sheet{results, grid, stats})
+}
+
+// percent returns n as a percentage of total, rounded to one decimal place.
+func percent(n int, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(int(float64(n)*1000/float64(total)+0.5)) / 10
+}
+
+const xlsxHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+// xlsxStyles has the default cell style, a bold style for headers and a
+// date and time style.
+const xlsxStyles = xlsxHeader + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/>` +
+	`<xf numFmtId="22" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/></cellXfs>` +
+	`</styleSheet>`
+
+// Cell styles in xlsxStyles
+const (
+	styleHeader = 1
+	styleTime   = 2
+)
+
+// writeWorkbook writes sheets as an XLSX workbook. Strings are written
+// inline so the workbook needs no shared string table.
+func writeWorkbook(f io.Writer, sheets []sheet) error {
+	var types, workbook, rels bytes.Buffer
+	types.WriteString(xlsxHeader + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	workbook.WriteString(xlsxHeader + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	rels.WriteString(xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i, s := range sheets {
+		n := i + 1
+		fmt.Fprintf(&types, `<Override PartName="/xl/worksheets/sheet%v.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, n)
+		fmt.Fprintf(&workbook, `<sheet name="%v" sheetId="%v" r:id="rId%v"/>`, xmlEscape(s.name), n, n)
+		fmt.Fprintf(&rels, `<Relationship Id="rId%v" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%v.xml"/>`, n, n)
+	}
+	types.WriteString(`</Types>`)
+	workbook.WriteString(`</sheets></workbook>`)
+	fmt.Fprintf(&rels, `<Relationship Id="rId%v" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, len(sheets)+1)
+	rels.WriteString(`</Relationships>`)
+
+	type part struct {
+		name string
+		data []byte
+	}
+	parts := []part{
+		{"[Content_Types].xml", types.Bytes()},
+		{"_rels/.rels", []byte(xlsxHeader + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`)},
+		{"xl/workbook.xml", workbook.Bytes()},
+		{"xl/_rels/workbook.xml.rels", rels.Bytes()},
+		{"xl/styles.xml", []byte(xlsxStyles)},
+	}
+	for i, s := range sheets {
+		parts = append(parts, part{fmt.Sprintf("xl/worksheets/sheet%v.xml", i+1), s.xml()})
+	}
+
+	z := zip.NewWriter(f)
+	for _, part := range parts {
+		w, err := z.Create(part.name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(part.data); err != nil {
+			return err
+		}
+	}
+	return z.Close()
+}
+
+// xml returns the worksheet XML for a sheet.
+func (s sheet) xml() []byte {
+	var b bytes.Buffer
+	b.WriteString(xlsxHeader + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	b.WriteString(`<row r="1">`)
+	for col, name := range s.header {
+		if len(name) > 0 {
+			fmt.Fprintf(&b, `<c r="%v1" t="inlineStr" s="%v"><is><t>%v</t></is></c>`, columnName(col), styleHeader, xmlEscape(name))
+		}
+	}
+	b.WriteString(`</row>`)
+	for i, row := range s.rows {
+		r := i + 2
+		fmt.Fprintf(&b, `<row r="%v">`, r)
+		for col, value := range row {
+			ref := columnName(col) + strconv.Itoa(r)
+			switch v := value.(type) {
+			case string:
+				if len(v) > 0 {
+					fmt.Fprintf(&b, `<c r="%v" t="inlineStr"><is><t>%v</t></is></c>`, ref, xmlEscape(v))
+				}
+			case int:
+				fmt.Fprintf(&b, `<c r="%v"><v>%v</v></c>`, ref, v)
+			case float64:
+				fmt.Fprintf(&b, `<c r="%v"><v>%v</v></c>`, ref, strconv.FormatFloat(v, 'f', -1, 64))
+			case time.Time:
+				if !v.IsZero() {
+					fmt.Fprintf(&b, `<c r="%v" s="%v"><v>%v</v></c>`, ref, styleTime, strconv.FormatFloat(excelTime(v), 'f', -1, 64))
+				}
+			}
+		}
+		b.WriteString(`</row>`)
+	}
+	b.WriteString(`</sheetData></worksheet>`)
+	return b.Bytes()
+}
+
+// columnName returns the spreadsheet column name for col, where 0 is A.
+func columnName(col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name
+}
+
+// excelTime returns t in local time as a spreadsheet date, the number of
+// days since December 30, 1899.
+func excelTime(t time.Time) float64 {
+	_, offset := t.Zone()
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return t.Add(time.Duration(offset)*time.Second).Sub(epoch).Hours() / 24
+}
+
+func xmlEscape(s string) string {
+	var b bytes.Buffer
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,197 @@
+// Package format turns a car's stickers into the clue and emergency strings
+// used by the Scoring Program spreadsheet.
+//
+// Clues and Emergencies take the stickers still on the card, so a false
+// value is a clue that was visited or an emergency that was opened.
+// ParseClues and ParseEmergencies read their output back.
+package format
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Clues returns the clues visited as streaks of letters, for example
+// "a-c, f, x-b". present[0] is clue A. A streak that runs past the last clue
+// rolls over to the first, so "x-b" is X, Y, Z, A and B.
+func Clues(present []bool) string {
+	// This code is hideous but it works. Don't judge me.
+	type streak struct {
+		start int
+		end   int
+	}
+
+	clueNum := len(present)
+	// visited reports whether clue i (1 based) was visited
+	visited := func(i int) bool {
+		return !present[i-1]
+	}
+	if clueNum == 0 {
+		return ""
+	}
+
+	var streaks []streak
+
+	var currentStreak streak
+	end := clueNum
+	start := 0
+	clue := 0
+	// first handle rollover
+	if visited(clueNum) && visited(1) {
+		// Z and A are populated
+		// find the start of the streak
+		first := 0
+		for i := clueNum; i >= 1; i-- {
+			if visited(i) {
+				first = i
+				clue++
+			} else {
+				// start of streak found
+				currentStreak.start = first
+				end = first
+				break
+			}
+		}
+		if clue == clueNum {
+			// all clues found
+			currentStreak = streak{1, clueNum}
+		} else {
+			last := 0
+			for i := 1; i < end; i++ {
+				if visited(i) {
+					last = i
+				} else {
+					// start of streak found
+					currentStreak.end = last
+					start = last
+					break
+				}
+			}
+		}
+		streaks = append(streaks, currentStreak)
+		end--
+	}
+
+	if clue != clueNum {
+		currentStreak = streak{0, 0}
+		for i := start + 1; i <= end; i++ {
+			if visited(i) {
+				// got a clue
+				if currentStreak.start == 0 {
+					currentStreak.start = i
+					currentStreak.end = i
+				} else {
+					currentStreak.end = i
+				}
+			} else {
+				if currentStreak.start != 0 {
+					streaks = append(streaks, currentStreak)
+					currentStreak = streak{0, 0}
+				}
+			}
+		}
+		if currentStreak.start != 0 {
+			// append the last streak
+			streaks = append(streaks, currentStreak)
+		}
+	}
+	streakStr := ""
+	for i := 0; i < len(streaks); i++ {
+		if len(streakStr) > 0 {
+			streakStr = streakStr + ", "
+		}
+		streakStr = streakStr + ClueLetter(streaks[i].start)
+		if streaks[i].start != streaks[i].end {
+			streakStr = streakStr + "-" + ClueLetter(streaks[i].end)
+		}
+	}
+	return strings.ToLower(streakStr)
+}
+
+// ClueLetter returns the upper case letter for clue number clue, where 1 is
+// A.
+func ClueLetter(clue int) string {
+	return string(rune(64 + clue))
+}
+
+// Emergencies returns the emergencies opened as a comma separated list of
+// numbers. present[0] is emergency 1.
+func Emergencies(present []bool) string {
+	// process emergencies
+	emergencies := ""
+	for i := 1; i <= len(present); i++ {
+		if present[i-1] == false {
+			// emergency wasn't found so must've been opened
+			if len(emergencies) > 0 {
+				emergencies = emergencies + ", "
+			}
+			emergencies = emergencies + strconv.Itoa(i)
+		}
+	}
+	return emergencies
+}
+
+// ParseClues reads clue streaks written by Clues, for example "a-c, f" or
+// the rollover streak "x-b", and returns the clues still on the card for
+// clueNum clues. Letters may be upper or lower case.
+func ParseClues(s string, clueNum int) ([]bool, error) {
+	present := make([]bool, clueNum)
+	for i := range present {
+		present[i] = true
+	}
+	clue := func(letter string) (int, error) {
+		letter = strings.ToUpper(strings.TrimSpace(letter))
+		if len(letter) != 1 || letter[0] < 'A' || int(letter[0]-'A') >= clueNum {
+			return 0, fmt.Errorf("invalid clue %q", letter)
+		}
+		return int(letter[0]-'A') + 1, nil
+	}
+	for _, part := range strings.Split(s, ",") {
+		if len(strings.TrimSpace(part)) == 0 {
+			continue
+		}
+		ends := strings.SplitN(part, "-", 2)
+		start, err := clue(ends[0])
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if len(ends) == 2 {
+			if end, err = clue(ends[1]); err != nil {
+				return nil, err
+			}
+		}
+		// a streak that ends before it starts rolls over from the last
+		// clue to the first
+		for i := start; ; i = i%clueNum + 1 {
+			present[i-1] = false
+			if i == end {
+				break
+			}
+		}
+	}
+	return present, nil
+}
+
+// ParseEmergencies reads an emergency list written by Emergencies, for
+// example "3, 17", and returns the emergencies still on the card for num
+// emergencies.
+func ParseEmergencies(s string, num int) ([]bool, error) {
+	present := make([]bool, num)
+	for i := range present {
+		present[i] = true
+	}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if len(part) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 1 || n > num {
+			return nil, fmt.Errorf("invalid emergency %q", part)
+		}
+		present[n-1] = false
+	}
+	return present, nil
+}
@@ -0,0 +1,118 @@
+package format
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cluesLeft returns the clues still on a card of clueNum clues after the
+// clues in visited, as lower case letters, were visited.
+func cluesLeft(clueNum int, visited string) []bool {
+	present := make([]bool, clueNum)
+	for i := range present {
+		present[i] = !strings.ContainsRune(visited, rune('a'+i))
+	}
+	return present
+}
+
+func TestParseClues(t *testing.T) {
+	tests := []struct {
+		list    string
+		clueNum int
+		visited string
+		err     bool
+	}{
+		{list: "", clueNum: 26, visited: ""},
+		{list: "a-c, f", clueNum: 26, visited: "abcf"},
+		{list: "A-C,F", clueNum: 26, visited: "abcf"},
+		{list: "x-b", clueNum: 26, visited: "xyzab"},
+		{list: "a-z", clueNum: 26, visited: "abcdefghijklmnopqrstuvwxyz"},
+		// events with fewer stickers roll over at their last clue
+		{list: "t-b", clueNum: 20, visited: "tab"},
+		{list: "s-t", clueNum: 20, visited: "st"},
+		{list: "a-t", clueNum: 20, visited: "abcdefghijklmnopqrst"},
+		{list: "c, j-l", clueNum: 12, visited: "cjkl"},
+		{list: "u", clueNum: 20, err: true},
+		{list: "t-u", clueNum: 20, err: true},
+		{list: "a-", clueNum: 26, err: true},
+		{list: "ab", clueNum: 26, err: true},
+		{list: "1", clueNum: 26, err: true},
+	}
+	for _, tt := range tests {
+		present, err := ParseClues(tt.list, tt.clueNum)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseClues(%q, %v) = %v; want an error", tt.list, tt.clueNum, present)
+			}
+			continue
+		}
+		want := cluesLeft(tt.clueNum, tt.visited)
+		if err != nil || !reflect.DeepEqual(present, want) {
+			t.Errorf("ParseClues(%q, %v) = %v, %v; want %v", tt.list, tt.clueNum, present, err, want)
+		}
+	}
+}
+
+func TestCluesRoundTrip(t *testing.T) {
+	tests := []struct {
+		clueNum int
+		visited string
+		want    string
+	}{
+		{clueNum: 26, visited: "", want: ""},
+		{clueNum: 26, visited: "abcf", want: "a-c, f"},
+		{clueNum: 26, visited: "xyzab", want: "x-b"},
+		{clueNum: 26, visited: "z", want: "z"},
+		{clueNum: 20, visited: "tab", want: "t-b"},
+		{clueNum: 20, visited: "st", want: "s-t"},
+		{clueNum: 20, visited: "abcdefghijklmnopqrst", want: "a-t"},
+		{clueNum: 12, visited: "la", want: "l-a"},
+	}
+	for _, tt := range tests {
+		got := Clues(cluesLeft(tt.clueNum, tt.visited))
+		if got != tt.want {
+			t.Errorf("Clues(%v clues, visited %q) = %q; want %q", tt.clueNum, tt.visited, got, tt.want)
+			continue
+		}
+		present, err := ParseClues(got, tt.clueNum)
+		if err != nil || Clues(present) != got {
+			t.Errorf("ParseClues(%q, %v) does not read back: %v, %v", got, tt.clueNum, present, err)
+		}
+	}
+}
+
+func TestParseEmergencies(t *testing.T) {
+	tests := []struct {
+		list   string
+		num    int
+		opened []int
+		err    bool
+	}{
+		{list: "", num: 26},
+		{list: "3, 17", num: 26, opened: []int{3, 17}},
+		{list: "1,10", num: 10, opened: []int{1, 10}},
+		{list: "11", num: 10, err: true},
+		{list: "0", num: 10, err: true},
+		{list: "x", num: 10, err: true},
+	}
+	for _, tt := range tests {
+		present, err := ParseEmergencies(tt.list, tt.num)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseEmergencies(%q, %v) = %v; want an error", tt.list, tt.num, present)
+			}
+			continue
+		}
+		want := make([]bool, tt.num)
+		for i := range want {
+			want[i] = true
+		}
+		for _, n := range tt.opened {
+			want[n-1] = false
+		}
+		if err != nil || !reflect.DeepEqual(present, want) {
+			t.Errorf("ParseEmergencies(%q, %v) = %v, %v; want %v", tt.list, tt.num, present, err, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/sheets"
+)
+
+// sheetSets are the sheets thcount generate can make, by name.
+var sheetSets = []struct {
+	name   string
+	file   string
+	layout func(config.Event) ([]sheets.Page, error)
+}{
+	{"cards", "sticker-cards", sheets.StickerCards},
+	{"commands", "command-sheet", sheets.CommandSheet},
+	{"clear", "clear-sheets", sheets.ClearSheets},
+}
+
+// generate writes the barcode sheets for the event: thcount generate
+// [-format pdf|svg] [-out DIR] [cards] [commands] [clear].
+func generate(ev config.Event, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ExitOnError)
+	format := fs.String("format", "pdf", "Output format, pdf or svg")
+	out := fs.String("out", "sheets", "Directory to write the sheets to")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: %s generate [options] [cards] [commands] [clear]\n\nWrites the sticker cards, command sheet and car clear sheets for the event,\nor only the ones named.\n\nOptions:\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *format != "pdf" && *format != "svg" {
+		return fmt.Errorf("unknown format %q", *format)
+	}
+	wanted := make(map[string]bool)
+	for _, name := range fs.Args() {
+		found := false
+		for _, set := range sheetSets {
+			found = found || set.name == name
+		}
+		if !found {
+			return fmt.Errorf("unknown sheets %q: use cards, commands or clear", name)
+		}
+		wanted[name] = true
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	for _, set := range sheetSets {
+		if len(wanted) > 0 && !wanted[set.name] {
+			continue
+		}
+		pages, err := set.layout(ev)
+		if err != nil {
+			return fmt.Errorf("%v: %v", set.name, err)
+		}
+		if *format == "pdf" {
+			filename := filepath.Join(*out, set.file+".pdf")
+			if err := writeSheet(filename, func(f *os.File) error { return sheets.WritePDF(f, pages) }); err != nil {
+				return err
+			}
+			fmt.Printf("Wrote %v\n", filename)
+			continue
+		}
+		for _, page := range pages {
+			page := page
+			filename := filepath.Join(*out, page.Name+".svg")
+			if err := writeSheet(filename, func(f *os.File) error { return sheets.WriteSVG(f, page) }); err != nil {
+				return err
+			}
+		}
+		if len(pages) > 0 {
+			fmt.Printf("Wrote %v to %v\n", filepath.Join(*out, pages[0].Name+".svg"), pages[len(pages)-1].Name+".svg")
+		}
+	}
+	return nil
+}
+
+// writeSheet creates filename and writes it with write.
+func writeSheet(filename string, write func(*os.File) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
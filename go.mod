@@ -0,0 +1,8 @@
+module github.com/awoodward/azth-scoringcount
+
+go 1.16
+
+require (
+	github.com/tarm/serial v0.0.0-20180830185346-98f6abe2eb07
+	golang.org/x/sys v0.0.0-20210423082822-04245dca01da // indirect
+)
@@ -0,0 +1,269 @@
+// Package replica forwards the changes made at a secondary counting station
+// to a primary station over HTTP, so the primary's dashboard shows the cars
+// counted at every station. Changed cars wait in QueueFile until the
+// primary accepts them, so changes made while the network is down are sent
+// when it comes back.
+package replica
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/client"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// QueueFile holds the cars changed but not yet accepted by the primary.
+const QueueFile = "replication.json"
+
+// RetryInterval is how long to wait before sending again after the primary
+// could not be reached.
+var RetryInterval = 5 * time.Second
+
+// LockedRetryInterval is how long to wait before sending a car again after
+// the primary reported it locked.
+var LockedRetryInterval = time.Minute
+
+// Status describes the forwarding of changes to the primary.
+type Status struct {
+	Primary string
+	Station string
+	// Pending is the number of changed cars waiting to be sent, including
+	// the Locked cars the primary has not accepted because they are locked
+	// there.
+	Pending   int
+	Locked    []int
+	LastSent  time.Time
+	LastError string
+}
+
+// Forwarder sends the cars changed at this station to the primary. Set
+// Changed as the count's ChangeFunc and start Run.
+type Forwarder struct {
+	count   *state.CountData
+	client  *client.Client
+	station string
+	wake    chan struct{}
+
+	mu sync.Mutex
+	// pending maps each changed car to the number of the change, so a car
+	// changed again while it is being sent stays queued
+	pending map[int]int
+	// locked maps the pending cars the primary reported locked to when to
+	// send them again
+	locked map[int]time.Time
+	// versions maps the cars whose count the primary last accepted as sent
+	// to their version there
+	versions  map[int]int
+	lastSeq   int
+	dirty     bool // pending has changed since it was saved
+	lastSent  time.Time
+	lastError string
+}
+
+// New returns a forwarder that sends the changes to count to the primary
+// station at primaryURL under the name station. Cars still queued in
+// QueueFile are sent first.
+func New(count *state.CountData, primaryURL string, station string) *Forwarder {
+	f := &Forwarder{
+		count:    count,
+		client:   client.New(primaryURL),
+		station:  station,
+		wake:     make(chan struct{}, 1),
+		pending:  make(map[int]int),
+		locked:   make(map[int]time.Time),
+		versions: make(map[int]int),
+	}
+	var cars []int
+	if b, err := ioutil.ReadFile(QueueFile); err == nil {
+		if err := json.Unmarshal(b, &cars); err != nil {
+			log.Printf("Error reading %v: %v\n", QueueFile, err)
+		}
+	}
+	for _, car := range cars {
+		f.queue(car)
+	}
+	return f
+}
+
+// queue adds a car to the pending cars. It must be called with mu held.
+func (f *Forwarder) queue(car int) {
+	if state.ValidCar(car) {
+		f.lastSeq++
+		f.pending[car] = f.lastSeq
+		f.dirty = true
+	}
+}
+
+// Changed queues a changed car to be sent. It does not block, so it can be
+// used as the count's ChangeFunc.
+func (f *Forwarder) Changed(car int) {
+	f.mu.Lock()
+	f.queue(car)
+	f.mu.Unlock()
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends changed cars to the primary as they change, and retries every
+// RetryInterval while it cannot be reached, until ctx is done.
+func (f *Forwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(RetryInterval)
+	defer ticker.Stop()
+	for {
+		f.writeQueue()
+		f.send(ctx)
+		f.writeQueue()
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Status returns the state of the forwarding.
+func (f *Forwarder) Status() Status {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	locked := make([]int, 0, len(f.locked))
+	for car := range f.locked {
+		locked = append(locked, car)
+	}
+	sort.Ints(locked)
+	return Status{
+		Primary:   f.client.BaseURL,
+		Station:   f.station,
+		Pending:   len(f.pending),
+		Locked:    locked,
+		LastSent:  f.lastSent,
+		LastError: f.lastError,
+	}
+}
+
+// send sends the pending cars to the primary, each with the version the
+// primary last returned for it. Cars are only taken off the queue once the
+// primary has accepted them, or refused them as invalid.
+// Cars locked on the primary stay queued and are sent again every
+// LockedRetryInterval until it accepts them.
+func (f *Forwarder) send(ctx context.Context) {
+	f.mu.Lock()
+	now := time.Now()
+	sent := make(map[int]int, len(f.pending))
+	versions := make(map[int]int, len(f.pending))
+	for car, seq := range f.pending {
+		if retry, ok := f.locked[car]; ok && now.Before(retry) {
+			continue
+		}
+		sent[car] = seq
+		versions[car] = f.versions[car]
+	}
+	f.mu.Unlock()
+	if len(sent) == 0 {
+		return
+	}
+
+	cars := make([]client.ReplicaCar, 0, len(sent))
+	for car := range sent {
+		r := f.count.ReplicaCar(car)
+		cars = append(cars, client.ReplicaCar{
+			Car:      r.Car,
+			Version:  versions[car],
+			Count:    r.Count[:],
+			Times:    client.CarTime(r.Times),
+			ScanTime: r.ScanTime,
+			Edited:   r.Edited,
+		})
+	}
+	sort.Slice(cars, func(i, j int) bool {
+		return cars[i].Car < cars[j].Car
+	})
+	result, err := f.client.Replicate(ctx, f.station, cars)
+
+	var apiErr *client.Error
+	refused := errors.As(err, &apiErr) && apiErr.Status >= 400 && apiErr.Status < 500
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err != nil {
+		f.lastError = err.Error()
+		if !refused {
+			// try again later
+			return
+		}
+		log.Printf("Primary refused cars: %v\n", err)
+	} else {
+		f.lastError = ""
+		f.lastSent = time.Now()
+	}
+	locked := make(map[int]bool, len(result.Locked))
+	for _, car := range result.Locked {
+		locked[car] = true
+	}
+	for car, seq := range sent {
+		if locked[car] {
+			f.locked[car] = time.Now().Add(LockedRetryInterval)
+			continue
+		}
+		delete(f.locked, car)
+		// a car the primary merged with its own changes is sent without a
+		// version next time, so it is merged again rather than replaced
+		if version, ok := result.Versions[car]; ok {
+			f.versions[car] = version
+		} else {
+			delete(f.versions, car)
+		}
+		if f.pending[car] == seq {
+			delete(f.pending, car)
+			f.dirty = true
+		}
+	}
+	for _, conflict := range result.Conflicts {
+		log.Printf("Car %v on the primary: %v\n", conflict.Car, conflict.Detail)
+	}
+	if len(result.Locked) > 0 {
+		log.Printf("Cars %v are locked on the primary and were not changed, trying again in %v\n", result.Locked, LockedRetryInterval)
+	}
+}
+
+// writeQueue saves the pending cars to QueueFile if they have changed, or
+// removes it if there are none. The file is replaced in one step so a crash
+// cannot leave it half written.
+func (f *Forwarder) writeQueue() {
+	f.mu.Lock()
+	if !f.dirty {
+		f.mu.Unlock()
+		return
+	}
+	f.dirty = false
+	cars := make([]int, 0, len(f.pending))
+	for car := range f.pending {
+		cars = append(cars, car)
+	}
+	f.mu.Unlock()
+	if len(cars) == 0 {
+		if err := os.Remove(QueueFile); err != nil && !os.IsNotExist(err) {
+			log.Printf("Error removing %v: %v\n", QueueFile, err)
+		}
+		return
+	}
+	sort.Ints(cars)
+	b, _ := json.Marshal(cars)
+	tmp := QueueFile + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0644); err != nil {
+		log.Printf("Error saving %v: %v\n", QueueFile, err)
+		return
+	}
+	if err := os.Rename(tmp, QueueFile); err != nil {
+		log.Printf("Error saving %v: %v\n", QueueFile, err)
+	}
+}
@@ -0,0 +1,126 @@
+package replica
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// primary serves the replication endpoint of a count, or fails every
+// request while down is set.
+type primary struct {
+	count *state.CountData
+	mu    sync.Mutex
+	down  bool
+}
+
+func (p *primary) setDown(down bool) {
+	p.mu.Lock()
+	p.down = down
+	p.mu.Unlock()
+}
+
+func (p *primary) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	p.mu.Lock()
+	down := p.down
+	p.mu.Unlock()
+	if down {
+		http.Error(w, "down", http.StatusServiceUnavailable)
+		return
+	}
+	var body struct {
+		Station string
+		Cars    []state.ReplicaCar
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	result, err := p.count.ApplyReplica(body.Station, body.Cars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+// inTempDir runs the test in a temporary directory, where the forwarder
+// keeps its QueueFile.
+func inTempDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replica")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.Chdir(wd)
+		os.RemoveAll(dir)
+	})
+}
+
+func TestForwarderQueue(t *testing.T) {
+	inTempDir(t)
+	p := &primary{count: state.New(), down: true}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+	ctx := context.Background()
+
+	secondary := state.New()
+	f := New(secondary, srv.URL, "b")
+	secondary.ChangeFunc = f.Changed
+	if err := secondary.Process("12-CL-A", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// while the primary is down the car stays queued, also in QueueFile
+	f.send(ctx)
+	f.writeQueue()
+	if status := f.Status(); status.Pending != 1 || status.LastError == "" {
+		t.Errorf("Status() = %+v; want one car pending and an error", status)
+	}
+	if _, err := os.Stat(QueueFile); err != nil {
+		t.Errorf("%v not written: %v", QueueFile, err)
+	}
+
+	// a restarted forwarder sends the queued car once the primary is back
+	p.setDown(false)
+	f = New(secondary, srv.URL, "b")
+	secondary.ChangeFunc = f.Changed
+	f.send(ctx)
+	f.writeQueue()
+	if status := f.Status(); status.Pending != 0 || status.LastError != "" {
+		t.Errorf("Status() = %+v; want nothing pending", status)
+	}
+	if _, err := os.Stat(QueueFile); !os.IsNotExist(err) {
+		t.Errorf("%v not removed: %v", QueueFile, err)
+	}
+	if !p.count.Stickers(12).Clues[0] {
+		t.Fatal("car 12 clue A not forwarded")
+	}
+
+	// the primary returned the car's version, so removing a sticker on the
+	// secondary removes it on the primary
+	stickers := secondary.Stickers(12)
+	stickers.Clues[0] = false
+	stickers.Version = 0
+	if err := secondary.SetStickers(stickers, state.Editor{}); err != nil {
+		t.Fatal(err)
+	}
+	f.send(ctx)
+	if p.count.Stickers(12).Clues[0] {
+		t.Error("car 12 clue A not removed on the primary")
+	}
+}
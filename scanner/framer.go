@@ -0,0 +1,121 @@
+package scanner
+
+import (
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+// Framing control bytes
+const (
+	stx = 0x02
+	etx = 0x03
+	cr  = '\r'
+	lf  = '\n'
+)
+
+// Framer splits the bytes read from a scanner into codes. Reads may split a
+// code at any byte; each code is returned exactly once, when its terminator
+// arrives or the inter-character timeout passes.
+type Framer struct {
+	framing  config.Framing
+	timeout  time.Duration
+	buf      []byte
+	inFrame  bool // STX seen and waiting for ETX
+	lastCR   bool // previous byte was a CR, for CRLF
+	lastByte time.Time
+}
+
+// NewFramer returns a framer for the given framing.
+func NewFramer(framing config.Framing) *Framer {
+	if len(framing.Terminator) == 0 {
+		framing.Terminator = config.FrameLine
+	}
+	return &Framer{
+		framing: framing,
+		timeout: time.Duration(framing.TimeoutMillis) * time.Millisecond,
+	}
+}
+
+// Write adds the bytes read at time now and returns the codes they complete.
+func (f *Framer) Write(p []byte, now time.Time) []string {
+	codes := f.Flush(now)
+	if len(p) == 0 {
+		return codes
+	}
+	f.lastByte = now
+	for _, b := range p {
+		switch f.framing.Terminator {
+		case config.FrameLine:
+			if b == cr || b == lf {
+				codes = f.emit(codes)
+				continue
+			}
+		case config.FrameCR:
+			if b == cr {
+				codes = f.emit(codes)
+				continue
+			}
+		case config.FrameLF:
+			if b == lf {
+				codes = f.emit(codes)
+				continue
+			}
+		case config.FrameCRLF:
+			if b == lf && f.lastCR {
+				// drop the CR already buffered
+				f.buf = f.buf[:len(f.buf)-1]
+				f.lastCR = false
+				codes = f.emit(codes)
+				continue
+			}
+			f.lastCR = b == cr
+		case config.FrameSTXETX:
+			switch {
+			case b == stx:
+				// a new frame discards anything left from a broken one
+				f.buf = f.buf[:0]
+				f.inFrame = true
+			case b == etx && f.inFrame:
+				f.inFrame = false
+				codes = f.emit(codes)
+			case f.inFrame:
+				f.buf = append(f.buf, b)
+			}
+			continue
+		}
+		f.buf = append(f.buf, b)
+		if f.framing.Terminator == config.FrameFixed && len(f.buf) >= f.framing.Length {
+			codes = f.emit(codes)
+		}
+	}
+	return codes
+}
+
+// Flush returns the buffered code if the inter-character timeout has passed
+// since the last byte. It should be called when a read returns no data.
+func (f *Framer) Flush(now time.Time) []string {
+	if f.timeout <= 0 || len(f.buf) == 0 || now.Sub(f.lastByte) < f.timeout {
+		return nil
+	}
+	f.inFrame = false
+	f.lastCR = false
+	return f.emit(nil)
+}
+
+// Pending returns the bytes of a code that has not been finished yet.
+func (f *Framer) Pending() string {
+	return string(f.buf)
+}
+
+// emit appends the buffered code to codes, skipping empty codes, and empties
+// the buffer.
+func (f *Framer) emit(codes []string) []string {
+	code := strings.TrimSpace(string(f.buf))
+	f.buf = f.buf[:0]
+	if len(code) == 0 {
+		return codes
+	}
+	return append(codes, code)
+}
@@ -0,0 +1,120 @@
+package scanner
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+var framerTests = []struct {
+	name    string
+	framing config.Framing
+	input   string
+	want    []string
+}{
+	{"line", config.Framing{Terminator: config.FrameLine}, "12-CL-A\r\n12-CL-B\n12-CL-C\r\r\n", []string{"12-CL-A", "12-CL-B", "12-CL-C"}},
+	{"default", config.Framing{}, "12-CL-A\n12-CL-B\r", []string{"12-CL-A", "12-CL-B"}},
+	{"cr", config.Framing{Terminator: config.FrameCR}, "12-CL-A\r\n12-CL-B\r", []string{"12-CL-A", "12-CL-B"}},
+	{"lf", config.Framing{Terminator: config.FrameLF}, "12-CL-A\n\r12-CL-B\r\n", []string{"12-CL-A", "12-CL-B"}},
+	{"crlf", config.Framing{Terminator: config.FrameCRLF}, "12-CL-A\r\n12-CL-B\r\n\r\n", []string{"12-CL-A", "12-CL-B"}},
+	{"crlf lone", config.Framing{Terminator: config.FrameCRLF}, "12-CL-A\n\r\n12-CL-B\r\n", []string{"12-CL-A", "12-CL-B"}},
+	{"stxetx", config.Framing{Terminator: config.FrameSTXETX}, "\x0212-CL-A\x03\r\n\x0212-CL-B\x03", []string{"12-CL-A", "12-CL-B"}},
+	{"stxetx broken", config.Framing{Terminator: config.FrameSTXETX}, "\x0212-CL\x0212-CL-A\x03noise\x03\x0212-CL-B\x03", []string{"12-CL-A", "12-CL-B"}},
+	{"fixed", config.Framing{Terminator: config.FrameFixed, Length: 7}, "12-CL-A12-CL-B", []string{"12-CL-A", "12-CL-B"}},
+}
+
+// writeSplit writes input to a new framer in three writes, split at i and
+// j, and returns the codes emitted.
+func writeSplit(framing config.Framing, input string, i int, j int, now time.Time) (*Framer, []string) {
+	f := NewFramer(framing)
+	var codes []string
+	codes = append(codes, f.Write([]byte(input[:i]), now)...)
+	codes = append(codes, f.Write([]byte(input[i:j]), now)...)
+	codes = append(codes, f.Write([]byte(input[j:]), now)...)
+	return f, codes
+}
+
+func TestFramerSplits(t *testing.T) {
+	now := time.Now()
+	for _, tt := range framerTests {
+		for i := 0; i <= len(tt.input); i++ {
+			for j := i; j <= len(tt.input); j++ {
+				f, codes := writeSplit(tt.framing, tt.input, i, j, now)
+				if !reflect.DeepEqual(codes, tt.want) {
+					t.Errorf("%v: split at %v and %v: got %q, want %q", tt.name, i, j, codes, tt.want)
+				}
+				if len(f.Pending()) > 0 {
+					t.Errorf("%v: split at %v and %v: %q left pending", tt.name, i, j, f.Pending())
+				}
+			}
+		}
+	}
+}
+
+func TestFramerCRLFAcrossWrites(t *testing.T) {
+	now := time.Now()
+	f := NewFramer(config.Framing{Terminator: config.FrameCRLF})
+	if codes := f.Write([]byte("12-CL-A\r"), now); len(codes) != 0 {
+		t.Errorf("code emitted before the LF: %q", codes)
+	}
+	codes := f.Write([]byte("\n12-CL-B\r"), now)
+	if !reflect.DeepEqual(codes, []string{"12-CL-A"}) {
+		t.Errorf("got %q, want [12-CL-A]", codes)
+	}
+	codes = f.Write([]byte("\n"), now)
+	if !reflect.DeepEqual(codes, []string{"12-CL-B"}) {
+		t.Errorf("got %q, want [12-CL-B]", codes)
+	}
+}
+
+func TestFramerTimeout(t *testing.T) {
+	framings := []config.Framing{
+		{Terminator: config.FrameNone, TimeoutMillis: 50},
+		{Terminator: config.FrameLine, TimeoutMillis: 50},
+		{Terminator: config.FrameCRLF, TimeoutMillis: 50},
+	}
+	input := "12-CL-A"
+	start := time.Now()
+	for _, framing := range framings {
+		for i := 0; i <= len(input); i++ {
+			for j := i; j <= len(input); j++ {
+				f, codes := writeSplit(framing, input, i, j, start)
+				if len(codes) != 0 {
+					t.Errorf("%v: split at %v and %v: emitted %q before the timeout", framing.Terminator, i, j, codes)
+				}
+				if codes := f.Flush(start.Add(49 * time.Millisecond)); len(codes) != 0 {
+					t.Errorf("%v: split at %v and %v: flushed %q before the timeout", framing.Terminator, i, j, codes)
+				}
+				codes = f.Flush(start.Add(50 * time.Millisecond))
+				if !reflect.DeepEqual(codes, []string{input}) {
+					t.Errorf("%v: split at %v and %v: flushed %q, want [%v]", framing.Terminator, i, j, codes, input)
+				}
+				if codes := f.Flush(start.Add(time.Second)); len(codes) != 0 {
+					t.Errorf("%v: split at %v and %v: flushed %q twice", framing.Terminator, i, j, codes)
+				}
+			}
+		}
+	}
+
+	// a write after the timeout finishes the code before it
+	f := NewFramer(config.Framing{Terminator: config.FrameNone, TimeoutMillis: 50})
+	f.Write([]byte("12-CL-A"), start)
+	codes := f.Write([]byte("12-CL-B"), start.Add(time.Second))
+	if !reflect.DeepEqual(codes, []string{"12-CL-A"}) {
+		t.Errorf("got %q, want [12-CL-A]", codes)
+	}
+	if f.Pending() != "12-CL-B" {
+		t.Errorf("pending %q, want 12-CL-B", f.Pending())
+	}
+}
+
+func TestFramerNoTimeout(t *testing.T) {
+	start := time.Now()
+	f := NewFramer(config.Framing{Terminator: config.FrameLine})
+	f.Write([]byte("12-CL-A"), start)
+	if codes := f.Flush(start.Add(time.Hour)); len(codes) != 0 {
+		t.Errorf("flushed %q without a timeout", codes)
+	}
+}
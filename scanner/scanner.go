@@ -0,0 +1,171 @@
+// Package scanner finds the serial barcode scanners attached to the computer
+// and feeds the codes they read to the count.
+package scanner
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/state"
+	"github.com/tarm/serial"
+)
+
+// Baud is the speed of the serial barcode scanners.
+const Baud = 19200
+
+// Open opens the serial port of a barcode scanner. A short inter-character
+// timeout in framing shortens the read timeout so it can be noticed.
+func Open(name string, framing config.Framing) (*serial.Port, error) {
+	readTimeout := time.Second * 1
+	if timeout := time.Duration(framing.TimeoutMillis) * time.Millisecond; timeout > 0 && timeout < 2*readTimeout {
+		readTimeout = timeout / 2
+		if readTimeout < 100*time.Millisecond {
+			// the serial driver counts in tenths of a second
+			readTimeout = 100 * time.Millisecond
+		}
+	}
+	c := &serial.Config{Name: name, Baud: Baud, ReadTimeout: readTimeout}
+	return serial.OpenPort(c)
+}
+
+// Worker reads codes from a scanner until the count is quitting, applying
+// each code to the count. framer splits the bytes read into codes.
+func Worker(s io.Reader, workerId int, count *state.CountData, framer *Framer) {
+	errorCount := 0
+	buf := make([]byte, 256)
+	for {
+		if count.Quitting() {
+			return
+		}
+		//log.Println("Ready")
+		if errorCount > 10 {
+			log.Println("Too many errors")
+			count.Quit()
+			return
+		}
+		n, err := s.Read(buf)
+		if err != nil {
+			if err == io.EOF {
+				// Normal end-of-file - nothing to read
+				processCodes(framer.Flush(time.Now()), workerId, count)
+			} else {
+				log.Printf("Error: %v\n", err)
+				errorCount++
+			}
+			continue
+		}
+		errorCount = 0
+		if n == 0 {
+			processCodes(framer.Flush(time.Now()), workerId, count)
+			continue
+		}
+		count.ScannerRead(workerId)
+		codes := framer.Write(buf[:n], time.Now())
+		if count.Debug {
+			log.Printf("[%v]length: %v data: %q codes: %q pending: %q\n", workerId, n, buf[:n], codes, framer.Pending())
+		}
+		processCodes(codes, workerId, count)
+	}
+}
+
+// Beep writes the beep command to a scanner's serial port.
+func Beep(w io.Writer, beep string) {
+	if len(beep) == 0 {
+		return
+	}
+	if _, err := w.Write([]byte(beep)); err != nil {
+		log.Printf("Error sending beep: %v\n", err)
+	}
+}
+
+// processCodes applies complete codes to the count, recording the ones that
+// are rejected.
+func processCodes(codes []string, workerId int, count *state.CountData) {
+	for _, code := range codes {
+		err := count.Process(code, workerId)
+		if count.Debug {
+			log.Printf("[%v]Code: %v, Valid: %v\n", workerId, code, err == nil)
+		}
+		if err != nil {
+			count.Reject(code, workerId, err)
+			continue
+		}
+		count.ScannerCounted(workerId)
+	}
+}
+
+// FindPorts returns the serial ports of the barcode scanners attached to the
+// computer.
+func FindPorts() ([]string, error) {
+	osType := runtime.GOOS
+	var patterns []string
+	switch osType {
+	case "darwin": // Mac OS X
+		patterns = []string{"/dev/cu.usbmodem*", "/dev/cu.usbserial*"}
+	case "linux":
+		patterns = []string{"/dev/serial/by-id/*"}
+	case "windows":
+		return getWindowsDevices(), nil
+	default:
+		return nil, fmt.Errorf("OS Not Supported %v", osType)
+	}
+
+	var portNames []string
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			log.Printf("Error: %v\n", err)
+		}
+		if len(matches) != 0 {
+			portNames = append(portNames, matches...)
+			log.Printf("Found : %v\n", matches)
+		}
+	}
+	return portNames, nil
+}
+
+// *** Windows ***/
+const WINDOWS_SERIAL = "wmic path Win32_PnPEntity Get Name"
+
+func getWindowsDevices() []string {
+	//get the list from wmic
+	getPorts := exec.Command("cmd", "/C", WINDOWS_SERIAL)
+	raw, err := getPorts.CombinedOutput()
+	if err != nil {
+		return nil
+	}
+	list := strings.Split(string(raw), "\r\n")
+	ports := make([]string, 0, 10)
+	regex, _ := regexp.Compile("(COM[0-9]+)")
+	for _, v := range list {
+		matches := regex.FindAllString(v, 1)
+		if len(matches) == 1 {
+			ports = append(ports, matches[0])
+		}
+	}
+	return testPorts(ports)
+}
+
+func testPorts(p []string) []string {
+	d := make([]string, 0, len(p))
+	for _, port := range p {
+		//try to open the port and read back its status string
+		c := &serial.Config{Name: port, Baud: 115200, ReadTimeout: time.Second * 5}
+		s, err := serial.OpenPort(c)
+		if err != nil {
+			fmt.Println("Error: ", err)
+			continue
+		}
+		defer s.Close()
+		d = append(d, port)
+	}
+	return d
+}
@@ -0,0 +1,127 @@
+package sheets
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// Helvetica, in thousandths of the font size, starting from the space.
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// textWidth returns the width of s in Helvetica at size points.
+func textWidth(s string, size float64) float64 {
+	width := 0
+	for _, r := range s {
+		if r >= ' ' && r <= '~' {
+			width += helveticaWidths[r-' ']
+		} else {
+			width += 556
+		}
+	}
+	return float64(width) * size / 1000
+}
+
+// pdfString returns s as a PDF string literal. Characters outside ASCII
+// are replaced, as the standard Helvetica font is used without embedding.
+func pdfString(s string) string {
+	var b strings.Builder
+	b.WriteByte('(')
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r < ' ' || r > '~':
+			b.WriteByte('?')
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte(')')
+	return b.String()
+}
+
+// content returns the PDF content stream that draws a page. PDF measures
+// from the bottom left corner, so y is flipped.
+func (p Page) content() []byte {
+	var b bytes.Buffer
+	for _, e := range p.elements {
+		switch e := e.(type) {
+		case text:
+			x := e.x
+			if e.center {
+				x -= textWidth(e.s, e.size) / 2
+			}
+			fmt.Fprintf(&b, "BT /F1 %.2f Tf %.2f %.2f Td %v Tj ET\n", e.size, x, PageHeight-e.y, pdfString(e.s))
+		case bars:
+			for i := 0; i < len(e.modules); {
+				if !e.modules[i] {
+					i++
+					continue
+				}
+				start := i
+				for i < len(e.modules) && e.modules[i] {
+					i++
+				}
+				fmt.Fprintf(&b, "%.3f %.3f %.3f %.3f re\n", e.x+float64(start)*e.module, PageHeight-e.y-e.height, float64(i-start)*e.module, e.height)
+			}
+			b.WriteString("f\n")
+		case box:
+			fmt.Fprintf(&b, "0.5 w 0.6 G %.2f %.2f %.2f %.2f re S 0 G\n", e.x, PageHeight-e.y-e.h, e.w, e.h)
+		}
+	}
+	return b.Bytes()
+}
+
+// WritePDF writes pages as a PDF document.
+func WritePDF(w io.Writer, pages []Page) error {
+	var b bytes.Buffer
+	var offsets []int
+	// objects are numbered from 1 in the order they are written
+	object := func(body string) {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%v 0 obj\n%v\nendobj\n", len(offsets), body)
+	}
+
+	b.WriteString("%PDF-1.4\n")
+	// 1 catalog, 2 page tree, 3 font, then a page and its content for
+	// each page
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%v 0 R", 4+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%v] /Count %v >>", strings.Join(kids, " "), len(pages)))
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	for i, p := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %v %v] /Resources << /Font << /F1 3 0 R >> >> /Contents %v 0 R >>",
+			PageWidth, PageHeight, 5+2*i))
+		var content bytes.Buffer
+		z := zlib.NewWriter(&content)
+		z.Write(p.content())
+		if err := z.Close(); err != nil {
+			return err
+		}
+		object(fmt.Sprintf("<< /Length %v /Filter /FlateDecode >>\nstream\n%s\nendstream", content.Len(), content.Bytes()))
+	}
+
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %v\n0000000000 65535 f \n", len(offsets)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %v /Root 1 0 R >>\nstartxref\n%v\n%%%%EOF\n", len(offsets)+1, xref)
+	_, err := w.Write(b.Bytes())
+	return err
+}
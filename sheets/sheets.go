@@ -0,0 +1,265 @@
+// Package sheets lays out the printed barcode sheets for an event: a
+// sticker card for each car, the command sheet and the car clear sheets.
+// Pages are US Letter and can be written as a PDF document or as one SVG
+// image per page. Barcodes are Code 39, like the hand-made sheets they
+// replace, and use the car-CMD-arg layout.
+package sheets
+
+import (
+	"fmt"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+// Page size and margin in points
+const (
+	PageWidth  = 612
+	PageHeight = 792
+	margin     = 36
+)
+
+// quietZone is the blank space needed either side of a barcode, in narrow
+// bars.
+const quietZone = 10
+
+// Page is one printed page. Positions are in points from the top left
+// corner.
+type Page struct {
+	// Name identifies the page, for example "card-12", and names its SVG
+	// file.
+	Name     string
+	elements []interface{}
+}
+
+// text is a line of Helvetica. y is the baseline.
+type text struct {
+	x, y   float64
+	size   float64
+	s      string
+	center bool
+}
+
+// bars is a barcode: a bar of width module for each true module.
+type bars struct {
+	x, y    float64
+	module  float64
+	height  float64
+	modules []bool
+}
+
+// box is a thin outline, for example where a sticker goes.
+type box struct {
+	x, y, w, h float64
+}
+
+func (p *Page) add(e interface{}) {
+	p.elements = append(p.elements, e)
+}
+
+func (p *Page) text(x float64, y float64, size float64, s string) {
+	p.add(text{x: x, y: y, size: size, s: s})
+}
+
+func (p *Page) centered(x float64, y float64, size float64, s string) {
+	p.add(text{x: x, y: y, size: size, s: s, center: true})
+}
+
+// barcode adds code as a barcode centred on x with its top at y, at most
+// width wide with its quiet zones and no wider than maxModule per narrow
+// bar, and prints the code below it. It returns the bottom of the code's
+// text.
+func (p *Page) barcode(code string, x float64, y float64, width float64, height float64, maxModule float64) (float64, error) {
+	modules, err := barcode.Code39(code)
+	if err != nil {
+		return y, err
+	}
+	module := width / float64(len(modules)+2*quietZone)
+	if module > maxModule {
+		module = maxModule
+	}
+	w := module * float64(len(modules))
+	p.add(bars{x: x - w/2, y: y, module: module, height: height, modules: modules})
+	size := height / 3
+	if size > 10 {
+		size = 10
+	}
+	p.centered(x, y+height+size+1, size, code)
+	return y + height + size + 1, nil
+}
+
+// codes returns a function that formats scans for the event, checking
+// that the event's barcode layouts read each one back.
+func codes(ev config.Event) (func(barcode.Scan) (string, error), error) {
+	grammar, err := ev.Grammar()
+	if err != nil {
+		return nil, err
+	}
+	limits := ev.Limits()
+	return func(scan barcode.Scan) (string, error) {
+		return grammar.Code(scan, limits)
+	}, nil
+}
+
+// title returns a page title with the event name, if it has one.
+func title(ev config.Event, s string) string {
+	if len(ev.Name) == 0 {
+		return s
+	}
+	return ev.Name + " - " + s
+}
+
+// StickerCards returns a sticker card for each car: the car's CA barcode,
+// then a sticker for each clue and each emergency.
+func StickerCards(ev config.Event) ([]Page, error) {
+	code, err := codes(ev)
+	if err != nil {
+		return nil, err
+	}
+	const (
+		columns = 4
+		rowH    = 43
+	)
+	cellW := float64(PageWidth-2*margin) / columns
+	pages := make([]Page, 0, ev.Cars)
+	for car := 1; car <= ev.Cars; car++ {
+		p := Page{Name: fmt.Sprintf("card-%v", car)}
+		p.centered(PageWidth/2, margin+18, 18, title(ev, fmt.Sprintf("Car %v", car)))
+		carCode, err := code(barcode.Scan{Car: car, Kind: barcode.KindCar})
+		if err != nil {
+			return nil, err
+		}
+		y, err := p.barcode(carCode, PageWidth/2, margin+28, 200, 32, 1)
+		if err != nil {
+			return nil, err
+		}
+		y += 8
+
+		sections := []struct {
+			heading string
+			kind    barcode.Kind
+			count   int
+			label   func(i int) string
+		}{
+			{"Clues", barcode.KindClue, ev.Clues, func(i int) string {
+				return fmt.Sprintf("Clue %v", string(rune('A'+i-1)))
+			}},
+			{"Emergencies", barcode.KindEmergency, ev.Emergencies, func(i int) string {
+				return fmt.Sprintf("Emergency %v", i)
+			}},
+		}
+		for _, section := range sections {
+			if section.count == 0 {
+				continue
+			}
+			p.text(margin, y+12, 12, section.heading)
+			y += 18
+			for i := 1; i <= section.count; i++ {
+				col := (i - 1) % columns
+				cellY := y + float64((i-1)/columns)*rowH
+				cellX := margin + float64(col)*cellW
+				p.add(box{x: cellX + 2, y: cellY + 1, w: cellW - 4, h: rowH - 2})
+				p.centered(cellX+cellW/2, cellY+9, 7, section.label(i))
+				c, err := code(barcode.Scan{Car: car, Kind: section.kind, Index: i})
+				if err != nil {
+					return nil, err
+				}
+				if _, err := p.barcode(c, cellX+cellW/2, cellY+12, cellW-4, 20, 0.75); err != nil {
+					return nil, err
+				}
+			}
+			y += float64((section.count+columns-1)/columns) * rowH
+		}
+		pages = append(pages, p)
+	}
+	return pages, nil
+}
+
+// command is one barcode on the command sheet.
+type command struct {
+	label string
+	note  string
+	scan  barcode.Scan
+}
+
+// CommandSheet returns the command sheet: SAVE, a SAVE for each export
+// format, STATUS, UNDO, QUIT and the global CLEAR.
+func CommandSheet(ev config.Event) ([]Page, error) {
+	code, err := codes(ev)
+	if err != nil {
+		return nil, err
+	}
+	commands := []command{
+		{"Save", "Save the count and write the exports", barcode.Scan{Kind: barcode.KindSave}},
+	}
+	for i, format := range ev.Exports {
+		commands = append(commands, command{"Save " + format.Name, "Save and also write the " + format.Name + " export", barcode.Scan{Kind: barcode.KindSave, Index: i + 1}})
+	}
+	commands = append(commands,
+		command{"Status", "Log the number of cars counted", barcode.Scan{Kind: barcode.KindStatus}},
+		command{"Undo", "Undo this scanner's last scan", barcode.Scan{Kind: barcode.KindUndo}},
+		command{"Quit", "Save and stop the counting program", barcode.Scan{Kind: barcode.KindQuit}},
+		command{"Clear all cars", "Clears the count of every car that is not locked", barcode.Scan{Kind: barcode.KindClear}},
+	)
+
+	const (
+		rowH    = 90
+		perPage = (PageHeight - 2*margin - 40) / rowH
+	)
+	var pages []Page
+	for i, cmd := range commands {
+		if i%perPage == 0 {
+			pages = append(pages, Page{Name: fmt.Sprintf("commands-%v", len(pages)+1)})
+			pages[len(pages)-1].centered(PageWidth/2, margin+18, 18, title(ev, "Command Sheet"))
+		}
+		p := &pages[len(pages)-1]
+		y := float64(margin + 40 + (i%perPage)*rowH)
+		p.text(margin, y+24, 16, cmd.label)
+		p.text(margin, y+40, 9, cmd.note)
+		c, err := code(cmd.scan)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.barcode(c, PageWidth-margin-140, y+8, 280, 48, 1.2); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
+
+// ClearSheets returns sheets with a CLEAR barcode for each car, to start a
+// car's count again.
+func ClearSheets(ev config.Event) ([]Page, error) {
+	code, err := codes(ev)
+	if err != nil {
+		return nil, err
+	}
+	const (
+		columns = 3
+		rowH    = 80
+		rows    = (PageHeight - 2*margin - 40) / rowH
+		perPage = columns * rows
+	)
+	cellW := float64(PageWidth-2*margin) / columns
+	var pages []Page
+	for car := 1; car <= ev.Cars; car++ {
+		i := car - 1
+		if i%perPage == 0 {
+			pages = append(pages, Page{Name: fmt.Sprintf("clear-%v", len(pages)+1)})
+			pages[len(pages)-1].centered(PageWidth/2, margin+18, 18, title(ev, "Car Clear Sheet"))
+		}
+		p := &pages[len(pages)-1]
+		n := i % perPage
+		x := margin + float64(n%columns)*cellW + cellW/2
+		y := float64(margin + 40 + (n/columns)*rowH)
+		p.centered(x, y+14, 12, fmt.Sprintf("Clear car %v", car))
+		c, err := code(barcode.Scan{Car: car, Kind: barcode.KindClear})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := p.barcode(c, x, y+20, cellW-8, 36, 1); err != nil {
+			return nil, err
+		}
+	}
+	return pages, nil
+}
@@ -0,0 +1,51 @@
+package sheets
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+)
+
+// WriteSVG writes a page as an SVG image the size of the page.
+func WriteSVG(w io.Writer, p Page) error {
+	b := bufio.NewWriter(w)
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%vpt" height="%vpt" viewBox="0 0 %v %v">`+"\n", PageWidth, PageHeight, PageWidth, PageHeight)
+	fmt.Fprintf(b, `<rect width="%v" height="%v" fill="white"/>`+"\n", PageWidth, PageHeight)
+	for _, e := range p.elements {
+		switch e := e.(type) {
+		case text:
+			anchor := ""
+			if e.center {
+				anchor = ` text-anchor="middle"`
+			}
+			fmt.Fprintf(b, `<text x="%.2f" y="%.2f" font-family="Helvetica, Arial, sans-serif" font-size="%.2f"%v>`, e.x, e.y, e.size, anchor)
+			xml.EscapeText(b, []byte(e.s))
+			b.WriteString("</text>\n")
+		case bars:
+			writeSVGBars(b, e)
+		case box:
+			fmt.Fprintf(b, `<rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="none" stroke="#999" stroke-width="0.5"/>`+"\n", e.x, e.y, e.w, e.h)
+		}
+	}
+	b.WriteString("</svg>\n")
+	return b.Flush()
+}
+
+// writeSVGBars writes a barcode as a single path with a rectangle for
+// each bar.
+func writeSVGBars(w io.Writer, e bars) {
+	fmt.Fprint(w, `<path fill="black" d="`)
+	for i := 0; i < len(e.modules); {
+		if !e.modules[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(e.modules) && e.modules[i] {
+			i++
+		}
+		fmt.Fprintf(w, "M%.3f %.3fh%.3fv%.3fh%.3fz", e.x+float64(start)*e.module, e.y, float64(i-start)*e.module, e.height, -float64(i-start)*e.module)
+	}
+	fmt.Fprint(w, `"/>`+"\n")
+}
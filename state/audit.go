@@ -0,0 +1,101 @@
+package state
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/format"
+)
+
+// Editor identifies who made a manual edit.
+type Editor struct {
+	User       string
+	RemoteAddr string
+}
+
+func (e Editor) String() string {
+	if len(e.User) == 0 {
+		return e.RemoteAddr
+	}
+	if len(e.RemoteAddr) == 0 {
+		return e.User
+	}
+	return e.User + " (" + e.RemoteAddr + ")"
+}
+
+// StickerChange is a sticker changed by an edit. Before and After are true
+// if the sticker was on the card.
+type StickerChange struct {
+	Code   string
+	Before bool
+	After  bool
+}
+
+// Edit is the audit record of a manual edit of a car.
+type Edit struct {
+	ID          int
+	Car         int
+	Time        time.Time
+	User        string
+	RemoteAddr  string
+	Description string
+	Changes     []StickerChange
+	// the clues and emergencies before and after, for example "a-c, f"
+	CluesBefore       string
+	CluesAfter        string
+	EmergenciesBefore string
+	EmergenciesAfter  string
+}
+
+// audit records a manual edit of a car, comparing its row before the edit
+// with the row now. It must be called with mu held.
+func (c *CountData) audit(car int, by Editor, description string, before [TotalCol]bool) {
+	c.lastEdit++
+	edit := Edit{
+		ID:          c.lastEdit,
+		Car:         car,
+		Time:        time.Now(),
+		User:        by.User,
+		RemoteAddr:  by.RemoteAddr,
+		Description: description,
+	}
+	after := c.thCount[car]
+	edit.Changes = diffRows(car, before, after)
+	edit.CluesBefore = format.Clues(before[1+ClueOffset : 1+ClueOffset+c.event.Clues])
+	edit.CluesAfter = format.Clues(after[1+ClueOffset : 1+ClueOffset+c.event.Clues])
+	edit.EmergenciesBefore = format.Emergencies(before[1+EmergencyOffset : 1+EmergencyOffset+c.event.Emergencies])
+	edit.EmergenciesAfter = format.Emergencies(after[1+EmergencyOffset : 1+EmergencyOffset+c.event.Emergencies])
+	c.edits = append(c.edits, edit)
+	c.edited[car] = true
+	c.addTimeline(TimelineEntry{Time: edit.Time, Car: car, Scanner: ScannerWeb, By: by.String(), Kind: TimelineEdit, Detail: description})
+
+	log.Printf("Car %v: %v by %v: %v\n", car, description, by, strings.Join(changeCodes(edit.Changes), " "))
+}
+
+// changeCodes lists sticker changes as codes, with + for a sticker added
+// and - for one removed.
+func changeCodes(changes []StickerChange) []string {
+	codes := make([]string, 0, len(changes))
+	for _, change := range changes {
+		if change.After {
+			codes = append(codes, "+"+change.Code)
+		} else {
+			codes = append(codes, "-"+change.Code)
+		}
+	}
+	return codes
+}
+
+// CarEdits returns the manual edits of a car, most recent first.
+func (c *CountData) CarEdits(car int) []Edit {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	edits := make([]Edit, 0)
+	for i := len(c.edits) - 1; i >= 0; i-- {
+		if c.edits[i].Car == car {
+			edits = append(edits, c.edits[i])
+		}
+	}
+	return edits
+}
@@ -0,0 +1,360 @@
+package state
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/format"
+)
+
+// carClues returns the clue columns of a car's row. Index 0 is clue A.
+func (c *CountData) carClues(car int) []bool {
+	return c.thCount[car][1+ClueOffset : 1+ClueOffset+ClueNum]
+}
+
+// carEmergencies returns the emergency columns of a car's row. Index 0 is
+// emergency 1.
+func (c *CountData) carEmergencies(car int) []bool {
+	return c.thCount[car][1+EmergencyOffset : 1+EmergencyOffset+ClueNum]
+}
+
+// eventClues returns the clue columns of a car's row that the event uses.
+// Columns past the event's last clue have no sticker and are left out of
+// counts, lists and scores. It must be called with mu held.
+func (c *CountData) eventClues(car int) []bool {
+	return c.carClues(car)[:c.event.Clues]
+}
+
+// eventEmergencies returns the emergency columns of a car's row that the
+// event uses. It must be called with mu held.
+func (c *CountData) eventEmergencies(car int) []bool {
+	return c.carEmergencies(car)[:c.event.Emergencies]
+}
+
+// Stickers returns the stickers still on a car's card.
+func (c *CountData) Stickers(car int) Stickers {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stickers(car)
+}
+
+func (c *CountData) stickers(car int) Stickers {
+	var editData Stickers
+	editData.CarNum = car
+	copy(editData.Emergencies[:], c.carEmergencies(car))
+	copy(editData.Clues[:], c.carClues(car))
+	editData.Version = c.versions[car]
+	return editData
+}
+
+// SetStickers replaces the stickers recorded for a car. A car with any
+// sticker is marked as scanned. Locked cars cannot be changed, and the
+// stickers are refused with an error wrapping ErrStale if they carry a
+// Version and the car has changed since. The edit is recorded in the car's
+// audit history.
+func (c *CountData) SetStickers(editData Stickers, by Editor) error {
+	if !ValidCar(editData.CarNum) {
+		return fmt.Errorf("invalid car %v", editData.CarNum)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.checkUnlocked(editData.CarNum); err != nil {
+		return err
+	}
+	car := editData.CarNum
+	if editData.Version != 0 && editData.Version != c.versions[car] {
+		return fmt.Errorf("car %v: %w (version %v, now %v)", car, ErrStale, editData.Version, c.versions[car])
+	}
+	description := fmt.Sprintf("Edit car %v", car)
+	c.record(ActionEdit, ScannerWeb, car, description, []int{car})
+	before := c.thCount[car]
+	c.setStickers(editData)
+	c.audit(car, by, description, before)
+	return nil
+}
+
+// ImportResult lists the cars set by ImportStickers and the locked cars it
+// left as they were.
+type ImportResult struct {
+	Imported []int
+	Locked   []int
+}
+
+// ImportStickers replaces the stickers of several cars, for example from an
+// old export, as one action that can be undone. Each car is marked as
+// scanned and the import is recorded in its audit history as an import from
+// source. Locked cars are left as they are.
+func (c *CountData) ImportStickers(cars []Stickers, by Editor, source string) (ImportResult, error) {
+	result := ImportResult{Imported: []int{}, Locked: []int{}}
+	for _, stickers := range cars {
+		if !ValidCar(stickers.CarNum) {
+			return result, fmt.Errorf("invalid car %v", stickers.CarNum)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	imported := make([]Stickers, 0, len(cars))
+	for _, stickers := range cars {
+		if c.locks[stickers.CarNum].Locked {
+			result.Locked = append(result.Locked, stickers.CarNum)
+			continue
+		}
+		imported = append(imported, stickers)
+		result.Imported = append(result.Imported, stickers.CarNum)
+	}
+	description := fmt.Sprintf("Import from %v", source)
+	c.record(ActionImport, ScannerWeb, 0, description, result.Imported)
+	for _, stickers := range imported {
+		car := stickers.CarNum
+		before := c.thCount[car]
+		c.setStickers(stickers)
+		c.thCount[car][0] = true
+		c.audit(car, by, description, before)
+	}
+	log.Printf("Imported %v cars from %v\n", len(result.Imported), source)
+	return result, nil
+}
+
+func (c *CountData) setStickers(editData Stickers) {
+	car := editData.CarNum
+	c.bump(car)
+	copy(c.carEmergencies(car), editData.Emergencies[:])
+	copy(c.carClues(car), editData.Clues[:])
+	for i := 1; i < TotalCol; i++ {
+		if c.thCount[car][i] {
+			c.thCount[car][0] = true
+			break
+		}
+	}
+}
+
+// CarEmergencies returns the emergencies a car opened, for example "3, 17".
+func (c *CountData) CarEmergencies(car int) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return format.Emergencies(c.eventEmergencies(car))
+}
+
+// CarClues returns the clues a car visited as streaks, for example "a-c, f".
+func (c *CountData) CarClues(car int) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return format.Clues(c.eventClues(car))
+}
+
+// HasCars reports whether any car has been scanned.
+func (c *CountData) HasCars() bool {
+	return c.CarsCounted() > 0
+}
+
+// CarsCounted returns the number of cars that have been scanned.
+func (c *CountData) CarsCounted() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cars := 0
+	for i := 1; i < CarMax; i++ {
+		if c.thCount[i][0] == false {
+			// no scans for car
+			continue
+		}
+		cars++
+	}
+	return cars
+}
+
+// BuildCarData returns the counted data for every car, indexed by car
+// number. Index 0 is unused.
+func (c *CountData) BuildCarData() []CarData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buildCarData()
+}
+
+func (c *CountData) buildCarData() []CarData {
+	carList := make([]CarData, CarMax)
+	ranks := c.ranks()
+	for i := 1; i < CarMax; i++ {
+		carList[i] = c.carData(i)
+		carList[i].Rank = ranks[i]
+	}
+	return carList
+}
+
+// ExportData is the whole count at one moment, so an export cannot mix cars
+// read before and after a scan. Cars, Stickers and Times are indexed by car
+// number as returned by BuildCarData.
+type ExportData struct {
+	Event    config.Event
+	Cars     []CarData
+	Stickers []Stickers
+	Times    [CarMax]CarTime
+	Tally    TallyData
+}
+
+// ExportData returns the event and the data, stickers and times of every
+// car, read together.
+func (c *CountData) ExportData() ExportData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := ExportData{
+		Event:    c.event,
+		Cars:     c.buildCarData(),
+		Stickers: make([]Stickers, CarMax),
+		Times:    *c.thTimes,
+		Tally:    c.tally(),
+	}
+	for car := range data.Stickers {
+		data.Stickers[car] = c.stickers(car)
+	}
+	return data
+}
+
+// Car returns the counted data for a car.
+func (c *CountData) Car(car int) CarData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data := c.carData(car)
+	if ValidCar(car) {
+		data.Rank = c.ranks()[car]
+	}
+	return data
+}
+
+func (c *CountData) carData(car int) CarData {
+	var currentCar CarData
+	currentCar.CarNum = car
+	currentCar.Scanned = c.thCount[car][0]
+	currentCar.EmergencyList = format.Emergencies(c.eventEmergencies(car))
+	currentCar.ClueList = format.Clues(c.eventClues(car))
+	currentCar.Emergencies, currentCar.Clues = c.solveCount(car)
+	currentCar.Emergencies = c.event.Emergencies - currentCar.Emergencies
+	currentCar.Clues = c.event.Clues - currentCar.Clues
+	currentCar.ScanTime = c.scanTime[car]
+	currentCar.Duplicates, currentCar.RapidRepeats = c.duplicateCount(car)
+	currentCar.Status = c.carStatus(car)
+	currentCar.Lock = c.locks[car]
+	currentCar.Edited = c.edited[car]
+	currentCar.Score = c.score(car)
+	return currentCar
+}
+
+// SolveCount returns the number of emergency and clue stickers still on a
+// car's card, out of the event's emergencies and clues.
+func (c *CountData) SolveCount(car int) (int, int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.solveCount(car)
+}
+
+func (c *CountData) solveCount(car int) (int, int) {
+	emergencies := 0
+	clues := 0
+	for _, present := range c.eventEmergencies(car) {
+		if present {
+			emergencies++
+		}
+	}
+	for _, present := range c.eventClues(car) {
+		if present {
+			clues++
+		}
+	}
+	return emergencies, clues
+}
+
+// ClearCar removes all scans for a car. Locked cars cannot be cleared.
+func (c *CountData) ClearCar(car int) error {
+	return c.clearCarFrom(car, ScannerWeb)
+}
+
+// clearCarFrom clears a car for a CLEAR code read by scanner.
+func (c *CountData) clearCarFrom(car int, scanner int) error {
+	if !ValidCar(car) {
+		return fmt.Errorf("invalid car %v", car)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := c.checkUnlocked(car); err != nil {
+		return err
+	}
+	c.record(ActionClear, scanner, car, fmt.Sprintf("Clear car %v", car), []int{car})
+	c.addTimeline(TimelineEntry{Car: car, Scanner: scanner, Kind: TimelineClear})
+	c.clearCar(car)
+	return nil
+}
+
+func (c *CountData) clearCar(car int) {
+	for i := 0; i < TotalCol; i++ {
+		c.thCount[car][i] = false
+	}
+	c.verify[car] = [TotalCol]bool{}
+	c.edited[car] = false
+	c.bump(car)
+	c.clearScans(car)
+	log.Printf("Car %v data cleared", car)
+}
+
+// ClearAll removes all scans and times for every car that is not locked.
+func (c *CountData) ClearAll() {
+	c.clearAllFrom(ScannerWeb)
+}
+
+// clearAllFrom clears every car for a global CLEAR code read by scanner.
+func (c *CountData) clearAllFrom(scanner int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	unlocked := make([]int, 0, CarMax)
+	for car := 0; car < CarMax; car++ {
+		if !c.locks[car].Locked {
+			unlocked = append(unlocked, car)
+		}
+	}
+	c.record(ActionClearAll, scanner, 0, "Clear all cars", unlocked)
+	c.addTimeline(TimelineEntry{Scanner: scanner, Kind: TimelineClearAll})
+	locked := 0
+	for car := 0; car < CarMax; car++ {
+		if c.locks[car].Locked {
+			locked++
+			continue
+		}
+		c.thCount[car] = [TotalCol]bool{}
+		c.verify[car] = [TotalCol]bool{}
+		c.thTimes[car] = CarTime{}
+		c.stickerScans[car] = [TotalCol]StickerScan{}
+		c.edited[car] = false
+		c.bump(car)
+	}
+	if locked > 0 {
+		log.Printf("All data cleared except %v locked cars\n", locked)
+		return
+	}
+	log.Println("All data cleared")
+}
+
+// Tally returns the clue and emergency totals for all cars.
+func (c *CountData) Tally() TallyData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tally()
+}
+
+func (c *CountData) tally() TallyData {
+	var tally TallyData
+	tally.TotalClues = c.event.Cars * c.event.Clues
+	tally.TotalEmergencies = c.event.Cars * c.event.Emergencies
+	for car := 1; car < CarMax; car++ {
+		for _, present := range c.eventEmergencies(car) {
+			if present {
+				tally.CountedEmergencies++
+			}
+		}
+		for _, present := range c.eventClues(car) {
+			if present {
+				tally.CountedClues++
+			}
+		}
+	}
+	tally.LastSaved = c.lastSaved
+	return tally
+}
@@ -0,0 +1,195 @@
+package state
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+)
+
+// Station is the saved state of another counting station: its
+// CarStateFile, TimeStateFile and TimelineStateFile. Times and Timeline may
+// be empty, but without the timeline a car cleared on the station cannot be
+// told from one it never counted.
+type Station struct {
+	// Name identifies the station in conflicts and the edit history.
+	Name     string
+	Count    Matrix
+	Times    [CarMax]CarTime
+	Timeline []TimelineEntry
+}
+
+// ReadStation reads the state files of another station. times and timeline
+// may be nil if the station's TimeStateFile or TimelineStateFile is not
+// available.
+func ReadStation(name string, count io.Reader, times io.Reader, timeline io.Reader) (Station, error) {
+	st := Station{Name: name}
+	if err := json.NewDecoder(count).Decode(&st.Count); err != nil {
+		return st, fmt.Errorf("%v: %v: %v", name, CarStateFile, err)
+	}
+	if times != nil {
+		if err := json.NewDecoder(times).Decode(&st.Times); err != nil {
+			return st, fmt.Errorf("%v: %v: %v", name, TimeStateFile, err)
+		}
+	}
+	if timeline != nil {
+		if err := json.NewDecoder(timeline).Decode(&st.Timeline); err != nil {
+			return st, fmt.Errorf("%v: %v: %v", name, TimelineStateFile, err)
+		}
+	}
+	return st, nil
+}
+
+// Merge conflict kinds
+const (
+	// ConflictCleared is a car cleared on one station after the other
+	// station last scanned it.
+	ConflictCleared = "cleared"
+	// ConflictLocked is a locked car that another station counted
+	// differently.
+	ConflictLocked = "locked"
+	// ConflictStale is a car changed here since a forwarding station last
+	// sent it, which the station's count would have removed stickers from.
+	ConflictStale = "stale"
+)
+
+// MergeConflict is a car Merge left as it was for a scorer to resolve.
+type MergeConflict struct {
+	Car     int
+	Station string
+	Kind    string
+	Detail  string
+}
+
+// MergeResult lists the cars changed by Merge and the conflicts it found.
+type MergeResult struct {
+	Merged    []int
+	Conflicts []MergeConflict
+}
+
+// clearedAt returns when a car was last cleared in a timeline, by a clear of
+// the car or a global CLEAR, or the zero time if it never was.
+func clearedAt(timeline []TimelineEntry, car int) time.Time {
+	var t time.Time
+	for _, entry := range timeline {
+		if (entry.Kind == TimelineClear && entry.Car == car || entry.Kind == TimelineClearAll) && entry.Time.After(t) {
+			t = entry.Time
+		}
+	}
+	return t
+}
+
+// lastScannedAt returns when a car was last scanned or edited in a
+// timeline, or the zero time if the timeline does not say.
+func lastScannedAt(timeline []TimelineEntry, car int) time.Time {
+	var t time.Time
+	for _, entry := range timeline {
+		if entry.Car != car {
+			continue
+		}
+		switch entry.Kind {
+		case TimelineScan, TimelineRepeat, TimelineCar, TimelineEdit:
+			if entry.Time.After(t) {
+				t = entry.Time
+			}
+		}
+	}
+	return t
+}
+
+// clearedSince reports whether a car not counted on one side was cleared
+// there after the other side last scanned it. If the other side's scan time
+// is not known the clear cannot be placed, so it is not a conflict.
+func clearedSince(cleared time.Time, scanned time.Time) bool {
+	return !cleared.IsZero() && !scanned.IsZero() && cleared.After(scanned)
+}
+
+// Merge adds the sticker scans of other stations to the count. Each car
+// counted on a station gets the union of its stickers here and there, and
+// check-out and check-in times missing here are filled in. A car cleared on
+// one side after the other side last scanned it, or a locked car that
+// another station counted differently, is left as it is and reported as a
+// conflict. The merge is one action that can be undone, and each car it
+// changes is recorded in its audit history.
+func (c *CountData) Merge(stations []Station, by Editor) (MergeResult, error) {
+	result := MergeResult{Merged: []int{}, Conflicts: []MergeConflict{}}
+	if len(stations) == 0 {
+		return result, fmt.Errorf("no stations to merge")
+	}
+	names := make([]string, 0, len(stations))
+	for _, st := range stations {
+		names = append(names, st.Name)
+	}
+	description := fmt.Sprintf("Merge from %v", strings.Join(names, ", "))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rows := *c.thCount
+	times := *c.thTimes
+	scanTimes := *c.scanTime
+	changed := make([]bool, CarMax)
+	for _, st := range stations {
+		for car := 1; car < CarMax; car++ {
+			theirs := st.Count[car]
+			ours := c.thCount[car]
+			conflict := func(kind string, format string, args ...interface{}) {
+				result.Conflicts = append(result.Conflicts, MergeConflict{Car: car, Station: st.Name, Kind: kind, Detail: fmt.Sprintf(format, args...)})
+			}
+			if !theirs[0] {
+				if cleared := clearedAt(st.Timeline, car); ours[0] && clearedSince(cleared, c.scanTime[car]) {
+					conflict(ConflictCleared, "cleared on %v at %v but counted here", st.Name, cleared.Format("15:04:05"))
+				}
+				continue
+			}
+			if cleared := clearedAt(c.timeline, car); !ours[0] && clearedSince(cleared, lastScannedAt(st.Timeline, car)) {
+				conflict(ConflictCleared, "cleared here at %v but counted on %v", cleared.Format("15:04:05"), st.Name)
+				continue
+			}
+			union := rows[car]
+			for i := range union {
+				union[i] = union[i] || theirs[i]
+			}
+			if c.locks[car].Locked {
+				if union != ours {
+					conflict(ConflictLocked, "locked here but counted differently on %v", st.Name)
+				}
+				continue
+			}
+			if union != rows[car] {
+				rows[car] = union
+				changed[car] = true
+			}
+			if times[car].CheckOut.IsZero() && !st.Times[car].CheckOut.IsZero() {
+				times[car].CheckOut = st.Times[car].CheckOut
+				changed[car] = true
+			}
+			if times[car].CheckIn.IsZero() && !st.Times[car].CheckIn.IsZero() {
+				times[car].CheckIn = st.Times[car].CheckIn
+				changed[car] = true
+			}
+			if scanned := lastScannedAt(st.Timeline, car); scanned.After(scanTimes[car]) {
+				scanTimes[car] = scanned
+			}
+		}
+	}
+	for car := range changed {
+		if changed[car] {
+			result.Merged = append(result.Merged, car)
+		}
+	}
+	if len(result.Merged) > 0 {
+		c.record(ActionMerge, ScannerWeb, 0, description, result.Merged)
+	}
+	for _, car := range result.Merged {
+		before := c.thCount[car]
+		c.thCount[car] = rows[car]
+		c.thTimes[car] = times[car]
+		c.scanTime[car] = scanTimes[car]
+		c.bump(car)
+		c.audit(car, by, description, before)
+	}
+	log.Printf("Merged %v cars from %v with %v conflicts\n", len(result.Merged), strings.Join(names, ", "), len(result.Conflicts))
+	return result, nil
+}
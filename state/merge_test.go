@@ -0,0 +1,75 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+func TestMerge(t *testing.T) {
+	c := New()
+	clueA := stickerColumn(barcode.KindClue, 1)
+	clueB := stickerColumn(barcode.KindClue, 2)
+	scanCodes(t, c, 1, "12-CL-A", "13-CL-A", "14-CL-A")
+	if err := c.Lock(14, "test"); err != nil {
+		t.Fatal(err)
+	}
+	scanned := time.Now()
+
+	st := Station{Name: "b"}
+	checkOut := scanned.Add(-time.Hour)
+	// car 12 counted on both stations, with a check-out time only there
+	st.Count[12][0] = true
+	st.Count[12][clueB] = true
+	st.Times[12].CheckOut = checkOut
+	// car 13 cleared there after it was scanned here
+	st.Timeline = append(st.Timeline, TimelineEntry{Time: scanned.Add(time.Minute), Car: 13, Kind: TimelineClear})
+	// car 14 locked here but counted differently there
+	st.Count[14][0] = true
+	st.Count[14][clueB] = true
+	// car 15 only counted there
+	st.Count[15][0] = true
+	st.Count[15][clueB] = true
+	before := c.Matrix()
+
+	result, err := c.Merge([]Station{st}, Editor{User: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Merged) != 2 || result.Merged[0] != 12 || result.Merged[1] != 15 {
+		t.Errorf("Merge merged %v; want cars 12 and 15", result.Merged)
+	}
+	after := c.Matrix()
+	if !after[12][clueA] || !after[12][clueB] {
+		t.Errorf("car 12 = %v; want the union of clues A and B", after[12])
+	}
+	if !c.CarTimes()[12].CheckOut.Equal(checkOut) {
+		t.Errorf("car 12 check-out = %v; want %v from the station", c.CarTimes()[12].CheckOut, checkOut)
+	}
+	if after[15] != st.Count[15] {
+		t.Errorf("car 15 = %v; want %v from the station", after[15], st.Count[15])
+	}
+	conflicts := make(map[int]string)
+	for _, conflict := range result.Conflicts {
+		conflicts[conflict.Car] = conflict.Kind
+	}
+	if len(conflicts) != 2 || conflicts[13] != ConflictCleared || conflicts[14] != ConflictLocked {
+		t.Errorf("Merge conflicts = %+v; want car 13 cleared and car 14 locked", result.Conflicts)
+	}
+	if after[13] != before[13] || after[14] != before[14] {
+		t.Error("Merge changed a car in conflict")
+	}
+
+	// the merge is one action that can be undone
+	if _, err := c.UndoCar(15); err != nil {
+		t.Fatal(err)
+	}
+	if undone := c.Matrix(); undone[12] != before[12] || undone[15] != before[15] {
+		t.Errorf("cars 12 and 15 after undoing the merge = %v, %v; want %v, %v", undone[12], undone[15], before[12], before[15])
+	}
+
+	if _, err := c.Merge(nil, Editor{}); err == nil {
+		t.Error("Merge of no stations did not fail")
+	}
+}
@@ -0,0 +1,115 @@
+package state
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+)
+
+// ReadState loads the sticker matrix and car times saved by WriteState,
+// along with the rejected codes in ProblemStateFile, the sticker scan counts
+// in ScanStateFile, the second counts in VerifyStateFile, the car locks in
+// LockStateFile, the edit audit history in EditStateFile, the car
+// timelines in TimelineStateFile and the print check in
+// PrintCheckStateFile. Missing files are ignored.
+func (c *CountData) ReadState(carFilename string, timeFilename string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	readJSONFile(carFilename, c.thCount)
+	readJSONFile(timeFilename, c.thTimes)
+	readJSONFile(ProblemStateFile, &c.problems)
+	readJSONFile(VerifyStateFile, c.verify)
+	readJSONFile(LockStateFile, c.locks)
+	readJSONFile(TimelineStateFile, &c.timeline)
+	var reads []PrintRead
+	readJSONFile(PrintCheckStateFile, &reads)
+	for i := range reads {
+		c.printReads[reads[i].Code] = &reads[i]
+	}
+	audit := auditState{Edited: c.edited}
+	readJSONFile(EditStateFile, &audit)
+	c.edits = audit.Edits
+	for _, e := range c.edits {
+		if e.ID > c.lastEdit {
+			c.lastEdit = e.ID
+		}
+	}
+	var scans []StickerScan
+	readJSONFile(ScanStateFile, &scans)
+	for _, s := range scans {
+		if ValidCar(s.Car) && s.Kind.IsSticker() && s.Index > 0 && s.Index <= ClueNum {
+			c.stickerScans[s.Car][stickerColumn(s.Kind, s.Index)] = s
+		}
+	}
+	for _, p := range c.problems {
+		if p.ID > c.lastProblem {
+			c.lastProblem = p.ID
+		}
+	}
+}
+
+// WriteState saves the sticker matrix and car times to CarStateFile and
+// TimeStateFile, the rejected codes to ProblemStateFile, the sticker scan
+// counts to ScanStateFile, the second counts to VerifyStateFile, the car
+// locks to LockStateFile, the edit audit history to EditStateFile, the car
+// timelines to TimelineStateFile and the print check to
+// PrintCheckStateFile.
+func (c *CountData) WriteState() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	writeJSONFile(CarStateFile, c.thCount)
+	writeJSONFile(TimeStateFile, c.thTimes)
+	writeJSONFile(ProblemStateFile, c.problems)
+	writeJSONFile(VerifyStateFile, c.verify)
+	writeJSONFile(LockStateFile, c.locks)
+	writeJSONFile(TimelineStateFile, c.timeline)
+	writeJSONFile(EditStateFile, auditState{Edited: c.edited, Edits: c.edits})
+	scans := make([]StickerScan, 0)
+	for car := 1; car < CarMax; car++ {
+		scans = append(scans, c.carScans(car)...)
+	}
+	writeJSONFile(ScanStateFile, scans)
+	reads := make([]PrintRead, 0, len(c.printReads))
+	for _, r := range c.printReads {
+		reads = append(reads, *r)
+	}
+	sortPrintReads(reads)
+	writeJSONFile(PrintCheckStateFile, reads)
+}
+
+// auditState is the content of EditStateFile.
+type auditState struct {
+	Edited *[CarMax]bool
+	Edits  []Edit
+}
+
+// readJSONFile unmarshals a JSON file into v. A missing file leaves v
+// unchanged.
+func readJSONFile(filename string, v interface{}) {
+	_, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		// doesn't exist; keep the initial value
+		return
+	}
+	// read metadata from file
+	byteValue, err := ioutil.ReadFile(filename)
+	if err != nil {
+		log.Printf("Error reading %v: %v\n", filename, err)
+		return
+	}
+
+	// Unmarshal our byteArray which contains the
+	// jsonFile's content into v
+	if err := json.Unmarshal(byteValue, v); err != nil {
+		log.Printf("Error reading %v: %v\n", filename, err)
+	}
+}
+
+// writeJSONFile saves v as indented JSON.
+func writeJSONFile(filename string, v interface{}) {
+	file, _ := json.MarshalIndent(v, "", " ")
+	if err := ioutil.WriteFile(filename, file, 0644); err != nil {
+		log.Printf("Error saving state: %v\n", err)
+	}
+}
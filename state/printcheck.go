@@ -0,0 +1,213 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// PrintRead counts the reads of one code while checking printed stickers.
+// Codes that read as a sticker or car barcode are counted under that
+// sticker, with Code in the car-CMD-arg form. Other codes are kept as read,
+// with the reason they are not on a sticker card in Unexpected.
+type PrintRead struct {
+	Code        string
+	Car         int
+	Kind        barcode.Kind `json:",omitempty"`
+	Index       int
+	Unexpected  string
+	Reads       int
+	LastScanner int
+	LastTime    time.Time
+}
+
+// PrintCheckCar reports the checked stickers of one car. Expected counts the
+// codes on the car's sticker card, including its car barcode, and Read how
+// many of them have been read. Duplicates are the codes read more than
+// once and Unexpected the codes read for the car that are not on its card,
+// such as its CLEAR barcode.
+type PrintCheckCar struct {
+	Car        int
+	Expected   int
+	Read       int
+	Missing    []string
+	Duplicates []PrintRead
+	Unexpected []PrintRead
+}
+
+// Complete reports whether every code on the car's card was read exactly
+// once and nothing else was read for it.
+func (p PrintCheckCar) Complete() bool {
+	return p.Read == p.Expected && len(p.Duplicates) == 0 && len(p.Unexpected) == 0
+}
+
+// PrintCheckReport compares the codes read while checking printed stickers
+// with the sticker cards of the event. Cars lists the cars with at least
+// one code read and NotStarted the rest. Unexpected holds the codes that do
+// not read as any car, such as unreadable codes and global commands.
+type PrintCheckReport struct {
+	Expected   int
+	Read       int
+	Cars       []PrintCheckCar
+	NotStarted []int
+	Unexpected []PrintRead
+}
+
+// SetPrintChecking puts a scanner in print check mode, in which its scans
+// are checked against the event's sticker cards instead of being counted.
+// It ends the scanner's second count.
+func (c *CountData) SetPrintChecking(scanner int, on bool) {
+	if scanner < 0 || scanner >= ScannerMax {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanners[scanner].PrintChecking = on
+	if on {
+		c.scanners[scanner].Verifying = false
+	}
+	log.Printf("[%v]Print check mode: %v\n", scanner, on)
+}
+
+// printChecking reports whether a scanner's scans are checked against the
+// sticker cards. It must be called with mu held.
+func (c *CountData) printChecking(scanner int) bool {
+	if c.Command == CommandPrintCheck {
+		return true
+	}
+	return scanner >= 0 && scanner < ScannerMax && c.scanners[scanner].PrintChecking
+}
+
+// CheckPrint records a code read from a printed sheet without counting it,
+// and returns its reads so far. Reading an unexpected code, or a sticker
+// that has already been read, raises an alert. Repeated reads from the
+// same scanner inside the debounce window are ignored.
+func (c *CountData) CheckPrint(code string, scanner int) PrintRead {
+	c.mu.Lock()
+	read, alert := c.checkPrint(code, scanner, time.Now())
+	c.mu.Unlock()
+	c.alert(alert)
+	return read
+}
+
+// checkPrint records a code read from a printed sheet. It must be called
+// with mu held.
+func (c *CountData) checkPrint(code string, scanner int, now time.Time) (PrintRead, *Alert) {
+	read := PrintRead{Code: code}
+	scan, err := c.grammar.Parse(code, c.event.Limits())
+	switch {
+	case err != nil:
+		read.Unexpected = err.Error()
+	case scan.Car == 0:
+		read.Kind = scan.Kind
+		read.Unexpected = fmt.Sprintf("reads as the %v command", scan.Kind)
+	case scan.Kind.IsSticker() || scan.Kind == barcode.KindCar:
+		read = PrintRead{Code: scan.String(), Car: scan.Car, Kind: scan.Kind, Index: scan.Index}
+	default:
+		read.Car = scan.Car
+		read.Kind = scan.Kind
+		read.Unexpected = fmt.Sprintf("reads as %v for car %v", scan.Kind, scan.Car)
+	}
+
+	if r, ok := c.printReads[read.Code]; ok {
+		read = *r
+	}
+	if read.Reads > 0 && read.LastScanner == scanner && now.Sub(read.LastTime) < c.event.Debounce() {
+		return read, nil
+	}
+	read.Reads++
+	read.LastScanner = scanner
+	read.LastTime = now
+	c.printReads[read.Code] = &read
+
+	switch {
+	case len(read.Unexpected) > 0:
+		return read, c.newAlert(scanner, read.Car, code, fmt.Sprintf("Print check: %q is not on a sticker card: %v", code, read.Unexpected))
+	case read.Reads > 1:
+		return read, c.newAlert(scanner, read.Car, code, fmt.Sprintf("Print check: %v has been read %v times", read.Code, read.Reads))
+	}
+	return read, nil
+}
+
+// PrintCheckReport compares the codes read while checking printed stickers
+// with the sticker cards of the event.
+func (c *CountData) PrintCheckReport() PrintCheckReport {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := PrintCheckReport{
+		Cars:       make([]PrintCheckCar, 0),
+		NotStarted: make([]int, 0),
+		Unexpected: make([]PrintRead, 0),
+	}
+	carReads := make(map[int][]PrintRead)
+	for _, r := range c.printReads {
+		if r.Car == 0 {
+			report.Unexpected = append(report.Unexpected, *r)
+			continue
+		}
+		carReads[r.Car] = append(carReads[r.Car], *r)
+	}
+	sortPrintReads(report.Unexpected)
+
+	ev := c.event
+	for car := 1; car <= ev.Cars; car++ {
+		scans := []barcode.Scan{{Car: car, Kind: barcode.KindCar}}
+		for i := 1; i <= ev.Clues; i++ {
+			scans = append(scans, barcode.Scan{Car: car, Kind: barcode.KindClue, Index: i})
+		}
+		for i := 1; i <= ev.Emergencies; i++ {
+			scans = append(scans, barcode.Scan{Car: car, Kind: barcode.KindEmergency, Index: i})
+		}
+		report.Expected += len(scans)
+		if len(carReads[car]) == 0 {
+			report.NotStarted = append(report.NotStarted, car)
+			continue
+		}
+
+		p := PrintCheckCar{
+			Car:        car,
+			Expected:   len(scans),
+			Missing:    make([]string, 0),
+			Duplicates: make([]PrintRead, 0),
+			Unexpected: make([]PrintRead, 0),
+		}
+		for _, scan := range scans {
+			r, ok := c.printReads[scan.String()]
+			if !ok {
+				p.Missing = append(p.Missing, scan.String())
+				continue
+			}
+			p.Read++
+			if r.Reads > 1 {
+				p.Duplicates = append(p.Duplicates, *r)
+			}
+		}
+		for _, r := range carReads[car] {
+			if len(r.Unexpected) > 0 {
+				p.Unexpected = append(p.Unexpected, r)
+			}
+		}
+		sortPrintReads(p.Unexpected)
+		report.Read += p.Read
+		report.Cars = append(report.Cars, p)
+	}
+	return report
+}
+
+// sortPrintReads sorts reads by code.
+func sortPrintReads(reads []PrintRead) {
+	sort.Slice(reads, func(i, j int) bool {
+		return reads[i].Code < reads[j].Code
+	})
+}
+
+// ResetPrintCheck forgets every code read while checking printed stickers.
+func (c *CountData) ResetPrintCheck() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.printReads = make(map[string]*PrintRead)
+	log.Println("Print check reset")
+}
@@ -0,0 +1,66 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+func TestPrintCheck(t *testing.T) {
+	c := New()
+	ev := config.Default()
+	ev.Cars = 2
+	ev.Clues = 2
+	ev.Emergencies = 1
+	if err := c.SetEvent(ev); err != nil {
+		t.Fatal(err)
+	}
+	scanCodes(t, c, 1, "2-CL-A")
+	count := c.Matrix()
+	history := len(c.History())
+	alerts := 0
+	c.AlertFunc = func(Alert) { alerts++ }
+
+	c.SetPrintChecking(3, true)
+	c.SetPrintChecking(4, true)
+	scanCodes(t, c, 3, "1-CA-0", "1-CL-A", "1-CL-B", "1-EM-1", "1-CLEAR-0", "0-CLEAR-0", "xyz")
+	scanCodes(t, c, 4, "1-CL-A")
+
+	// nothing read while checking reaches the count, not even a CLEAR
+	if c.Matrix() != count || len(c.History()) != history {
+		t.Error("print check changed the count")
+	}
+	if alerts != 4 {
+		t.Errorf("print check raised %v alerts; want 4 for 1-CLEAR-0, 0-CLEAR-0, xyz and the second 1-CL-A", alerts)
+	}
+
+	report := c.PrintCheckReport()
+	if report.Expected != 8 || report.Read != 4 {
+		t.Errorf("PrintCheckReport() read %v of %v; want 4 of 8", report.Read, report.Expected)
+	}
+	if len(report.NotStarted) != 1 || report.NotStarted[0] != 2 {
+		t.Errorf("PrintCheckReport() not started = %v; want car 2", report.NotStarted)
+	}
+	if len(report.Unexpected) != 2 {
+		t.Errorf("PrintCheckReport() unexpected = %+v; want 0-CLEAR-0 and xyz", report.Unexpected)
+	}
+	if len(report.Cars) != 1 {
+		t.Fatalf("PrintCheckReport() cars = %+v; want car 1", report.Cars)
+	}
+	car := report.Cars[0]
+	if car.Complete() || len(car.Missing) != 0 || len(car.Duplicates) != 1 || car.Duplicates[0].Code != "1-CL-A" ||
+		len(car.Unexpected) != 1 || car.Unexpected[0].Code != "1-CLEAR-0" {
+		t.Errorf("car 1 = %+v; want 1-CL-A read twice and 1-CLEAR-0 unexpected", car)
+	}
+
+	// counting carries on after the check
+	c.SetPrintChecking(3, false)
+	scanCodes(t, c, 3, "2-CL-B")
+	if c.Matrix() == count {
+		t.Error("scan after the print check was not counted")
+	}
+	c.ResetPrintCheck()
+	if report := c.PrintCheckReport(); report.Read != 0 || len(report.Cars) != 0 {
+		t.Errorf("PrintCheckReport() after reset = %+v; want nothing read", report)
+	}
+}
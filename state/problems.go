@@ -0,0 +1,151 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// ScannerWeb is the scanner number recorded for codes sent through the web
+// interface or the JSON API.
+const ScannerWeb = -1
+
+// Problem statuses
+const (
+	ProblemOpen      = "open"
+	ProblemAssigned  = "assigned"
+	ProblemDismissed = "dismissed"
+	ProblemDamaged   = "damaged"
+)
+
+// Problem is a scanned code that was rejected, waiting for a scorer to
+// assign it to a sticker, dismiss it or mark it as a damaged sticker.
+type Problem struct {
+	ID      int
+	Code    string
+	Scanner int
+	Time    time.Time
+	Reason  string
+	Status  string
+	// Resolution is the sticker a problem was assigned to, for example
+	// "12-CL-A".
+	Resolution   string
+	ResolvedTime time.Time
+}
+
+// Open reports whether the problem still needs a scorer.
+func (p Problem) Open() bool {
+	return p.Status == ProblemOpen
+}
+
+// Reject records a code that could not be processed.
+func (c *CountData) Reject(code string, scanner int, reason error) Problem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastProblem++
+	p := Problem{
+		ID:      c.lastProblem,
+		Code:    code,
+		Scanner: scanner,
+		Time:    time.Now(),
+		Reason:  reason.Error(),
+		Status:  ProblemOpen,
+	}
+	c.problems = append(c.problems, p)
+	log.Printf("[%v]Rejected code %q: %v\n", scanner, code, reason)
+	return p
+}
+
+// Problems returns the rejected codes still open, oldest first.
+func (c *CountData) Problems() []Problem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	problems := make([]Problem, 0)
+	for _, p := range c.problems {
+		if p.Open() {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+// AllProblems returns every rejected code, oldest first.
+func (c *CountData) AllProblems() []Problem {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Problem(nil), c.problems...)
+}
+
+// problem returns the open problem with the given id. It must be called with
+// mu held.
+func (c *CountData) problem(id int) (*Problem, error) {
+	for i := range c.problems {
+		if c.problems[i].ID != id {
+			continue
+		}
+		if !c.problems[i].Open() {
+			return nil, fmt.Errorf("problem %v is already %v", id, c.problems[i].Status)
+		}
+		return &c.problems[i], nil
+	}
+	return nil, fmt.Errorf("no problem %v", id)
+}
+
+// AssignProblem applies a sticker scan in place of a rejected code. The
+// scan is applied and the problem resolved together, so two scorers
+// assigning the same problem cannot both apply a scan.
+func (c *CountData) AssignProblem(id int, scan barcode.Scan) error {
+	if !scan.Kind.IsSticker() {
+		return fmt.Errorf("problem %v can only be assigned to a clue or emergency sticker", id)
+	}
+	alert, err := c.assignProblem(id, scan)
+	c.alert(alert)
+	return err
+}
+
+func (c *CountData) assignProblem(id int, scan barcode.Scan) (*Alert, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, err := c.problem(id); err != nil {
+		return nil, err
+	}
+	if err := scan.Validate(c.event.Limits()); err != nil {
+		return nil, err
+	}
+	alert, err := c.scanSticker(scan, ScannerWeb)
+	if err != nil {
+		return alert, err
+	}
+	return alert, c.resolve(id, ProblemAssigned, scan.String())
+}
+
+// DismissProblem closes a rejected code without changing the count.
+func (c *CountData) DismissProblem(id int) error {
+	return c.resolveProblem(id, ProblemDismissed, "")
+}
+
+// MarkDamaged closes a rejected code as a damaged sticker.
+func (c *CountData) MarkDamaged(id int) error {
+	return c.resolveProblem(id, ProblemDamaged, "")
+}
+
+func (c *CountData) resolveProblem(id int, status string, resolution string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.resolve(id, status, resolution)
+}
+
+// resolve closes an open problem. It must be called with mu held.
+func (c *CountData) resolve(id int, status string, resolution string) error {
+	p, err := c.problem(id)
+	if err != nil {
+		return err
+	}
+	p.Status = status
+	p.Resolution = resolution
+	p.ResolvedTime = time.Now()
+	log.Printf("Problem %v (%q) %v %v\n", id, p.Code, status, resolution)
+	return nil
+}
@@ -0,0 +1,55 @@
+package state
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+func TestAssignProblem(t *testing.T) {
+	c := New()
+	p := c.Reject("12-CL-?", 1, errors.New("smudged"))
+	scan := barcode.Scan{Car: 12, Kind: barcode.KindClue, Index: 3}
+
+	// scorers assigning the problem at the same time apply one scan
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = c.AssignProblem(p.ID, scan)
+		}(i)
+	}
+	wg.Wait()
+	assigned := 0
+	for _, err := range errs {
+		if err == nil {
+			assigned++
+		}
+	}
+	if assigned != 1 {
+		t.Errorf("AssignProblem from %v scorers assigned %v times; want once: %v", len(errs), assigned, errs)
+	}
+	scans := c.StickerScans(12)
+	if len(scans) != 1 || scans[0].Count != 1 {
+		t.Errorf("StickerScans(12) = %+v; want clue C scanned once", scans)
+	}
+	if problems := c.Problems(); len(problems) != 0 {
+		t.Errorf("Problems() = %+v; want none open", problems)
+	}
+
+	// a scan that cannot be applied leaves the problem open
+	p = c.Reject("13-CL-?", 1, errors.New("smudged"))
+	if err := c.Lock(13, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.AssignProblem(p.ID, barcode.Scan{Car: 13, Kind: barcode.KindClue, Index: 1}); err == nil {
+		t.Error("AssignProblem to a locked car did not fail")
+	}
+	if problems := c.Problems(); len(problems) != 1 {
+		t.Errorf("Problems() = %+v; want the problem still open", problems)
+	}
+}
@@ -0,0 +1,195 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/format"
+)
+
+// ProcessCode applies a barcode sent through the web interface to the count.
+// It returns false if the code is not valid.
+func (c *CountData) ProcessCode(code string) bool {
+	err := c.Process(code, ScannerWeb)
+	if err != nil && c.Debug {
+		log.Println(err)
+	}
+	return err == nil
+}
+
+// Process parses a scanned barcode with the event's barcode grammar and
+// applies it to the count. scanner is the number of the scanner that read
+// it, or ScannerWeb. The error describes why a code was rejected. Codes read
+// by a scanner checking printed stickers are only checked.
+func (c *CountData) Process(code string, scanner int) error {
+	if len(code) == 0 {
+		// Windows seems to return a zero length string
+		return nil
+	}
+	c.mu.Lock()
+	checking := c.printChecking(scanner)
+	c.mu.Unlock()
+	if checking {
+		c.CheckPrint(code, scanner)
+		return nil
+	}
+	scan, err := c.Parse(code)
+	if err != nil {
+		return err
+	}
+	return c.Apply(scan, scanner)
+}
+
+// Parse parses a barcode with the event's barcode grammar.
+func (c *CountData) Parse(code string) (barcode.Scan, error) {
+	c.mu.Lock()
+	grammar := c.grammar
+	limits := c.event.Limits()
+	c.mu.Unlock()
+	return grammar.Parse(code, limits)
+}
+
+// Apply applies a parsed scan from a scanner to the count.
+func (c *CountData) Apply(scan barcode.Scan, scanner int) error {
+	if err := scan.Validate(c.Event().Limits()); err != nil {
+		return err
+	}
+	car := scan.Car
+	switch scan.Kind {
+	case barcode.KindQuit:
+		log.Println("Quitting...")
+		c.save()
+		c.Quit()
+		return nil
+	case barcode.KindSave:
+		if scan.Index > 0 {
+			return c.export(scan.Index)
+		}
+		c.save()
+		return nil
+	case barcode.KindStatus:
+		c.Status()
+		return nil
+	case barcode.KindClear:
+		if car == 0 {
+			c.save()
+			c.clearAllFrom(scanner)
+		} else {
+			// clear car
+			return c.clearCarFrom(car, scanner)
+		}
+		return nil
+	case barcode.KindUndo:
+		// car 0 undoes the scanner's last action
+		var err error
+		if car == 0 {
+			_, err = c.UndoScanner(scanner)
+		} else {
+			_, err = c.undoCarFrom(car, scanner)
+		}
+		return err
+	case barcode.KindLock:
+		by := fmt.Sprintf("scanner %v", scanner)
+		if scanner == ScannerWeb {
+			by = "web"
+		}
+		return c.lockFrom(car, scanner, by)
+	}
+
+	alert, err := c.applySticker(scan, scanner)
+	c.alert(alert)
+	return err
+}
+
+// applySticker applies a sticker or CA scan, returning any alert it raised.
+// Scans from a verifying scanner go to the second count.
+func (c *CountData) applySticker(scan barcode.Scan, scanner int) (*Alert, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.scanSticker(scan, scanner)
+}
+
+// scanSticker applies a sticker or CA scan as applySticker does. It must be
+// called with mu held.
+func (c *CountData) scanSticker(scan barcode.Scan, scanner int) (*Alert, error) {
+	car := scan.Car
+	if c.verifying(scanner) {
+		return c.applyVerify(scan, scanner)
+	}
+	var before carSnapshot
+	if scan.Kind.IsSticker() {
+		if err := c.checkUnlocked(car); err != nil {
+			return nil, err
+		}
+		before = c.snapshot(car)
+	}
+	c.thCount[car][0] = true
+	switch scan.Kind {
+	case barcode.KindClue, barcode.KindEmergency: // Clue or Emergency
+		now := time.Now()
+		c.bump(car)
+		kind := TimelineScan
+		if c.recordScan(scan, scanner, now) {
+			// a rapid repeat changes nothing, so there is nothing to undo
+			kind = TimelineRepeat
+		} else {
+			c.recordSnapshots(ActionScan, scanner, car, fmt.Sprintf("Scan %v", scan), []carSnapshot{before})
+		}
+		c.addTimeline(TimelineEntry{Time: now, Car: car, Scanner: scanner, Kind: kind, Code: scan.String()})
+		c.thCount[car][stickerColumn(scan.Kind, scan.Index)] = true
+		c.scanTime[car] = now
+		return c.sessionScan(scan, scanner), nil
+	case barcode.KindCar: // Car
+		c.processCar(car, scanner)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("barcode %q: cannot apply %v", scan.Code, scan.Kind)
+}
+
+// processCar handles a CA barcode according to Command. When counting it
+// also opens or closes the scanner's counting session. It must be called
+// with mu held.
+func (c *CountData) processCar(car int, scanner int) {
+	switch c.Command {
+	case CommandCount:
+		c.addTimeline(TimelineEntry{Car: car, Scanner: scanner, Kind: TimelineCar, Code: barcode.Scan{Car: car, Kind: barcode.KindCar}.String()})
+		c.sessionCar(car, scanner)
+		emergencies, clues := c.solveCount(car)
+		fmt.Println("--------------------")
+		fmt.Printf("Car: %v scans: emergencies: %v \t clues: %v\n", car, emergencies, clues)
+		fmt.Printf("Car: %v emergencies opened (%v): %v \n", car, c.event.Emergencies-emergencies, format.Emergencies(c.eventEmergencies(car)))
+		fmt.Printf("Car: %v clues visited (%v): %v\n", car, c.event.Clues-clues, format.Clues(c.eventClues(car)))
+	case CommandCheckIn:
+		c.thTimes[car].CheckIn = time.Now()
+		c.addTimeline(TimelineEntry{Time: c.thTimes[car].CheckIn, Car: car, Scanner: scanner, Kind: TimelineCheckIn})
+		log.Printf("Car %v check-in time: %v\n", car, c.thTimes[car].CheckIn.Format("15:04:05"))
+	case CommandCheckOut:
+		c.thTimes[car].CheckOut = time.Now()
+		c.addTimeline(TimelineEntry{Time: c.thTimes[car].CheckOut, Car: car, Scanner: scanner, Kind: TimelineCheckOut})
+		log.Printf("Car %v check-out time: %v\n", car, c.thTimes[car].CheckOut.Format("15:04:05"))
+	}
+	c.changed(car)
+}
+
+// applyVerify records a sticker or CA scan in the second count. The scan
+// counts used for duplicate detection only follow the primary count. It must
+// be called with mu held.
+func (c *CountData) applyVerify(scan barcode.Scan, scanner int) (*Alert, error) {
+	car := scan.Car
+	if scan.Kind.IsSticker() {
+		c.record(ActionScan, scanner, car, fmt.Sprintf("Second count scan %v", scan), []int{car})
+	}
+	c.verify[car][0] = true
+	switch scan.Kind {
+	case barcode.KindClue, barcode.KindEmergency:
+		c.verify[car][stickerColumn(scan.Kind, scan.Index)] = true
+		c.addTimeline(TimelineEntry{Car: car, Scanner: scanner, Kind: TimelineSecond, Code: scan.String()})
+		return c.sessionScan(scan, scanner), nil
+	case barcode.KindCar:
+		c.sessionCar(car, scanner)
+		return nil, nil
+	}
+	return nil, fmt.Errorf("barcode %q: cannot apply %v", scan.Code, scan.Kind)
+}
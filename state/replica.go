@@ -0,0 +1,189 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReplicaCar is a car's count as one station forwards it to another.
+type ReplicaCar struct {
+	Car int
+	// Version is the receiving station's version of the car that Count is
+	// based on, as returned in ReplicaResult.Versions, or 0 if it is not
+	// known.
+	Version  int
+	Count    [TotalCol]bool
+	Times    CarTime
+	ScanTime time.Time
+	Edited   bool
+}
+
+// ReplicaResult lists the cars set by ApplyReplica, the locked cars it left
+// as they were and the conflicts it found. Versions maps each applied car
+// whose count now matches the station's to its version, for the station to
+// send back with the car's next change.
+type ReplicaResult struct {
+	Applied   []int
+	Locked    []int
+	Versions  map[int]int
+	Conflicts []MergeConflict
+}
+
+// ReplicaStation is a station that forwards its changes to this one.
+type ReplicaStation struct {
+	Name string
+	// LastUpdate is when the station last forwarded changes, and Cars the
+	// number of cars it has changed.
+	LastUpdate time.Time
+	Cars       int
+}
+
+// replicaStation tracks a station that forwards its changes.
+type replicaStation struct {
+	lastUpdate time.Time
+	cars       map[int]bool
+}
+
+func sameTimes(a CarTime, b CarTime) bool {
+	return a.CheckOut.Equal(b.CheckOut) && a.CheckIn.Equal(b.CheckIn)
+}
+
+// changed reports a change to a car to ChangeFunc. It must be called with
+// mu held.
+func (c *CountData) changed(car int) {
+	if c.ChangeFunc != nil {
+		c.ChangeFunc(car)
+	}
+}
+
+// ReplicaCar returns a car's count to forward to another station.
+func (c *CountData) ReplicaCar(car int) ReplicaCar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return ReplicaCar{
+		Car:      car,
+		Count:    c.thCount[car],
+		Times:    c.thTimes[car],
+		ScanTime: c.scanTime[car],
+		Edited:   c.edited[car],
+	}
+}
+
+// ApplyReplica sets cars to the counts forwarded by another station, which
+// counted or changed them last. A car whose Version is not its current
+// version here has changed since the station's count was based on it, so
+// the station's stickers and times are added to it as Merge does, and any
+// of its stickers the station's count would have removed are kept and
+// reported as a conflict. Each change is added to the car's timeline, and
+// the cars changed are recorded as one action that can be undone. Locked
+// cars are left as they are.
+func (c *CountData) ApplyReplica(station string, cars []ReplicaCar) (ReplicaResult, error) {
+	result := ReplicaResult{Applied: []int{}, Locked: []int{}, Versions: make(map[int]int), Conflicts: []MergeConflict{}}
+	for _, r := range cars {
+		if !ValidCar(r.Car) {
+			return result, fmt.Errorf("invalid car %v", r.Car)
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.replicas == nil {
+		c.replicas = make(map[string]*replicaStation)
+	}
+	st, ok := c.replicas[station]
+	if !ok {
+		st = &replicaStation{cars: make(map[int]bool)}
+		c.replicas[station] = st
+	}
+	st.lastUpdate = time.Now()
+	changed := make([]ReplicaCar, 0)
+	changedCars := make([]int, 0)
+	for _, r := range cars {
+		car := r.Car
+		if c.locks[car].Locked {
+			result.Locked = append(result.Locked, car)
+			continue
+		}
+		st.cars[car] = true
+		result.Applied = append(result.Applied, car)
+		if r.Version != c.versions[car] {
+			theirs := r.Count
+			r = c.mergeReplica(r)
+			if r.Count != theirs {
+				result.Conflicts = append(result.Conflicts, MergeConflict{Car: car, Station: station, Kind: ConflictStale,
+					Detail: fmt.Sprintf("changed here since %v last sent it, so stickers it removed were kept", station)})
+			}
+		}
+		if c.thCount[car] == r.Count && sameTimes(c.thTimes[car], r.Times) {
+			continue
+		}
+		changed = append(changed, r)
+		changedCars = append(changedCars, car)
+	}
+	if len(changed) > 0 {
+		c.record(ActionReplica, ScannerWeb, 0, fmt.Sprintf("Changes from %v", station), changedCars)
+	}
+	for _, r := range changed {
+		car := r.Car
+		changes := changeCodes(diffRows(car, c.thCount[car], r.Count))
+		c.thCount[car] = r.Count
+		c.thTimes[car] = r.Times
+		c.scanTime[car] = r.ScanTime
+		c.edited[car] = r.Edited
+		c.pruneScans(car)
+		c.bump(car)
+		c.addTimeline(TimelineEntry{Car: car, Scanner: ScannerWeb, By: station, Kind: TimelineReplica, Detail: strings.Join(changes, " ")})
+	}
+	for _, r := range cars {
+		if !c.locks[r.Car].Locked && c.thCount[r.Car] == r.Count {
+			result.Versions[r.Car] = c.versions[r.Car]
+		}
+	}
+	if len(result.Locked) > 0 {
+		log.Printf("Locked cars %v not changed by %v\n", result.Locked, station)
+	}
+	for _, conflict := range result.Conflicts {
+		log.Printf("Car %v: %v\n", conflict.Car, conflict.Detail)
+	}
+	return result, nil
+}
+
+// mergeReplica returns a forwarded car with the stickers and times of the
+// car here added to it, as Merge adds the counts of other stations. It must
+// be called with mu held.
+func (c *CountData) mergeReplica(r ReplicaCar) ReplicaCar {
+	car := r.Car
+	merged := r
+	for i := range merged.Count {
+		merged.Count[i] = c.thCount[car][i] || r.Count[i]
+	}
+	merged.Times = c.thTimes[car]
+	if merged.Times.CheckOut.IsZero() {
+		merged.Times.CheckOut = r.Times.CheckOut
+	}
+	if merged.Times.CheckIn.IsZero() {
+		merged.Times.CheckIn = r.Times.CheckIn
+	}
+	if c.scanTime[car].After(r.ScanTime) {
+		merged.ScanTime = c.scanTime[car]
+	}
+	merged.Edited = c.edited[car] || r.Edited
+	return merged
+}
+
+// ReplicaStations lists the stations that forward their changes to this
+// one, by name.
+func (c *CountData) ReplicaStations() []ReplicaStation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stations := make([]ReplicaStation, 0, len(c.replicas))
+	for name, st := range c.replicas {
+		stations = append(stations, ReplicaStation{Name: name, LastUpdate: st.lastUpdate, Cars: len(st.cars)})
+	}
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].Name < stations[j].Name
+	})
+	return stations
+}
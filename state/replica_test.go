@@ -0,0 +1,85 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+func TestApplyReplica(t *testing.T) {
+	c := New()
+	clueA := stickerColumn(barcode.KindClue, 1)
+	clueB := stickerColumn(barcode.KindClue, 2)
+	clueC := stickerColumn(barcode.KindClue, 3)
+
+	// a car the station counted from the primary's current version is set
+	// to the station's count
+	r := ReplicaCar{Car: 12, Version: c.CarVersion(12)}
+	r.Count[0] = true
+	r.Count[clueA] = true
+	result, err := c.ApplyReplica("b", []ReplicaCar{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Applied) != 1 || len(result.Conflicts) != 0 || result.Versions[12] != c.CarVersion(12) {
+		t.Fatalf("ApplyReplica = %+v; want car 12 applied at version %v", result, c.CarVersion(12))
+	}
+	if c.Matrix()[12] != r.Count {
+		t.Errorf("car 12 = %v; want %v", c.Matrix()[12], r.Count)
+	}
+
+	// a car scanned here since keeps its scans, and the stickers the
+	// station's count would have removed are reported
+	scanCodes(t, c, 1, "12-CL-B", "12-CL-C")
+	r.Count[clueA] = false
+	r.Version = result.Versions[12]
+	result, err = c.ApplyReplica("b", []ReplicaCar{r})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Conflicts) != 1 || result.Conflicts[0].Kind != ConflictStale {
+		t.Errorf("ApplyReplica of a stale car conflicts = %+v; want one %v conflict", result.Conflicts, ConflictStale)
+	}
+	if _, ok := result.Versions[12]; ok {
+		t.Errorf("ApplyReplica of a stale car returned version %v; want none", result.Versions[12])
+	}
+	row := c.Matrix()[12]
+	if !row[clueA] || !row[clueB] || !row[clueC] {
+		t.Errorf("car 12 = %v; want clues A, B and C kept", row)
+	}
+
+	// a station that has caught up can remove stickers, and their scans
+	// are forgotten
+	r.Count = row
+	r.Count[clueC] = false
+	r.Version = c.CarVersion(12)
+	if result, err = c.ApplyReplica("b", []ReplicaCar{r}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Conflicts) != 0 || c.Matrix()[12][clueC] {
+		t.Errorf("ApplyReplica = %+v, car 12 = %v; want clue C removed", result, c.Matrix()[12])
+	}
+	for _, s := range c.StickerScans(12) {
+		if s.Kind == barcode.KindClue && s.Index == 3 {
+			t.Errorf("scans of removed clue C kept: %+v", s)
+		}
+	}
+
+	// a locked car is left as it is
+	if err := c.Lock(12, "test"); err != nil {
+		t.Fatal(err)
+	}
+	locked := c.Matrix()[12]
+	r.Count = [TotalCol]bool{}
+	r.Version = c.CarVersion(12)
+	if result, err = c.ApplyReplica("b", []ReplicaCar{r}); err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Locked) != 1 || c.Matrix()[12] != locked {
+		t.Errorf("ApplyReplica of a locked car = %+v, car 12 = %v; want it left as %v", result, c.Matrix()[12], locked)
+	}
+
+	if _, err := c.ApplyReplica("b", []ReplicaCar{{Car: CarMax}}); err == nil {
+		t.Error("ApplyReplica of an invalid car did not fail")
+	}
+}
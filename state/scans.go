@@ -0,0 +1,173 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// StickerScan counts the scans of one sticker.
+type StickerScan struct {
+	Car   int
+	Kind  barcode.Kind
+	Index int
+	// Count is the number of separate scans. Rapid counts the extra reads
+	// from the same scanner inside the debounce window, which are not
+	// included in Count.
+	Count        int
+	Rapid        int
+	FirstScanner int
+	FirstTime    time.Time
+	LastScanner  int
+	LastTime     time.Time
+}
+
+// Duplicate reports whether the sticker was scanned more than once.
+func (s StickerScan) Duplicate() bool {
+	return s.Count > 1
+}
+
+// Code returns the sticker's barcode in the car-CMD-arg form.
+func (s StickerScan) Code() string {
+	return barcode.Scan{Car: s.Car, Kind: s.Kind, Index: s.Index}.String()
+}
+
+// DuplicateCar reports a car whose card has suspicious duplicate scans.
+type DuplicateCar struct {
+	Car        int
+	Duplicates []StickerScan
+	Rapid      int
+	Scanners   []int
+	Reasons    []string
+}
+
+// stickerColumn returns the matrix column of a sticker scan.
+func stickerColumn(kind barcode.Kind, index int) int {
+	if kind == barcode.KindClue {
+		return ClueOffset + index
+	}
+	return EmergencyOffset + index
+}
+
+// recordScan counts a sticker scan and reports whether it was a rapid
+// repeat. It must be called with mu held.
+func (c *CountData) recordScan(scan barcode.Scan, scanner int, now time.Time) bool {
+	col := stickerColumn(scan.Kind, scan.Index)
+	s := &c.stickerScans[scan.Car][col]
+	s.Car = scan.Car
+	s.Kind = scan.Kind
+	s.Index = scan.Index
+	if s.Count > 0 && s.LastScanner == scanner && now.Sub(s.LastTime) < c.event.Debounce() {
+		s.Rapid++
+		s.LastTime = now
+		return true
+	}
+	s.Count++
+	if s.Count == 1 {
+		s.FirstScanner = scanner
+		s.FirstTime = now
+	} else {
+		log.Printf("[%v]Duplicate scan of %v (%v scans)\n", scanner, s.Code(), s.Count)
+	}
+	s.LastScanner = scanner
+	s.LastTime = now
+	return false
+}
+
+// clearScans forgets the sticker scans of a car. It must be called with mu
+// held.
+func (c *CountData) clearScans(car int) {
+	c.stickerScans[car] = [TotalCol]StickerScan{}
+}
+
+// pruneScans forgets the scans of the stickers a car no longer has, after
+// its row has been set from another station. It must be called with mu
+// held.
+func (c *CountData) pruneScans(car int) {
+	for col := 1; col < TotalCol; col++ {
+		if !c.thCount[car][col] {
+			c.stickerScans[car][col] = StickerScan{}
+		}
+	}
+}
+
+// StickerScans returns the scan counts of every sticker of a car that has
+// been scanned.
+func (c *CountData) StickerScans(car int) []StickerScan {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.carScans(car)
+}
+
+func (c *CountData) carScans(car int) []StickerScan {
+	scans := make([]StickerScan, 0)
+	if !ValidCar(car) {
+		return scans
+	}
+	for _, s := range c.stickerScans[car] {
+		if s.Count > 0 {
+			scans = append(scans, s)
+		}
+	}
+	return scans
+}
+
+// duplicateCount returns the number of duplicated stickers and rapid
+// repeats of a car. It must be called with mu held.
+func (c *CountData) duplicateCount(car int) (int, int) {
+	duplicates := 0
+	rapid := 0
+	for _, s := range c.stickerScans[car] {
+		if s.Duplicate() {
+			duplicates++
+		}
+		rapid += s.Rapid
+	}
+	return duplicates, rapid
+}
+
+// DuplicateReport returns the cars whose duplicate scans look suspicious:
+// stickers scanned again by a different scanner, which suggests the card
+// was counted twice, or at least the event's SuspiciousDuplicates
+// duplicated stickers.
+func (c *CountData) DuplicateReport() []DuplicateCar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := make([]DuplicateCar, 0)
+	for car := 1; car < CarMax; car++ {
+		var dup DuplicateCar
+		dup.Car = car
+		otherScanner := 0
+		scanners := make(map[int]bool)
+		for _, s := range c.carScans(car) {
+			scanners[s.FirstScanner] = true
+			scanners[s.LastScanner] = true
+			dup.Rapid += s.Rapid
+			if !s.Duplicate() {
+				continue
+			}
+			dup.Duplicates = append(dup.Duplicates, s)
+			if s.FirstScanner != s.LastScanner {
+				otherScanner++
+			}
+		}
+		if otherScanner > 0 {
+			dup.Reasons = append(dup.Reasons, fmt.Sprintf("%v stickers scanned again by a different scanner", otherScanner))
+		}
+		if len(dup.Duplicates) >= c.event.SuspiciousDuplicates {
+			dup.Reasons = append(dup.Reasons, fmt.Sprintf("%v stickers scanned more than once", len(dup.Duplicates)))
+		}
+		if len(dup.Reasons) == 0 {
+			continue
+		}
+		for scanner := range scanners {
+			dup.Scanners = append(dup.Scanners, scanner)
+		}
+		sort.Ints(dup.Scanners)
+		report = append(report, dup)
+	}
+	return report
+}
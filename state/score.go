@@ -0,0 +1,33 @@
+package state
+
+// ranks returns the rank of every counted car by score, where cars with the
+// same score share a rank. Cars that have not been counted have rank 0. It
+// must be called with mu held.
+func (c *CountData) ranks() [CarMax]int {
+	var scores [CarMax]int
+	for car := 1; car < CarMax; car++ {
+		if c.thCount[car][0] {
+			scores[car] = c.score(car)
+		}
+	}
+	var ranks [CarMax]int
+	for car := 1; car < CarMax; car++ {
+		if !c.thCount[car][0] {
+			continue
+		}
+		ranks[car] = 1
+		for other := 1; other < CarMax; other++ {
+			if c.thCount[other][0] && scores[other] > scores[car] {
+				ranks[car]++
+			}
+		}
+	}
+	return ranks
+}
+
+// score returns a car's score under the event's scoring. It must be called
+// with mu held.
+func (c *CountData) score(car int) int {
+	emergencies, clues := c.solveCount(car)
+	return c.event.Score(c.event.Clues-clues, c.event.Emergencies-emergencies)
+}
@@ -0,0 +1,217 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/format"
+)
+
+// Session is the counting of one car's card on one scanner. It is opened by
+// scanning the car's CA barcode and closed by scanning it again or by
+// scanning another car.
+type Session struct {
+	ID      int
+	Scanner int
+	Car     int
+	Start   time.Time
+	End     time.Time
+	// Scans are the stickers of Car scanned in the session and Foreign
+	// the stickers of other cars.
+	Scans   []barcode.Scan
+	Foreign []barcode.Scan
+}
+
+// Open reports whether the session is still counting.
+func (s Session) Open() bool {
+	return s.End.IsZero()
+}
+
+// Counted returns the clues (as streaks) and emergencies of the event
+// scanned in the session.
+func (s Session) Counted(ev config.Event) (clues string, emergencies string) {
+	// format takes the stickers still on the card, which are the ones not
+	// scanned
+	clueLeft := make([]bool, ev.Clues)
+	emergencyLeft := make([]bool, ev.Emergencies)
+	for i := range clueLeft {
+		clueLeft[i] = true
+	}
+	for i := range emergencyLeft {
+		emergencyLeft[i] = true
+	}
+	for _, scan := range s.Scans {
+		switch {
+		case scan.Kind == barcode.KindClue && scan.Index <= ev.Clues:
+			clueLeft[scan.Index-1] = false
+		case scan.Kind == barcode.KindEmergency && scan.Index <= ev.Emergencies:
+			emergencyLeft[scan.Index-1] = false
+		}
+	}
+	return format.Clues(clueLeft), format.Emergencies(emergencyLeft)
+}
+
+// Summary describes what was counted in the session of an event.
+func (s Session) Summary(ev config.Event) string {
+	clues := 0
+	emergencies := 0
+	for _, scan := range s.Scans {
+		if scan.Kind == barcode.KindClue {
+			clues++
+		} else {
+			emergencies++
+		}
+	}
+	clueList, emergencyList := s.Counted(ev)
+	summary := fmt.Sprintf("Car %v on scanner %v: %v clue stickers (%v), %v emergency stickers (%v)",
+		s.Car, s.Scanner, clues, clueList, emergencies, emergencyList)
+	if len(s.Foreign) > 0 {
+		foreign := make([]string, 0, len(s.Foreign))
+		for _, scan := range s.Foreign {
+			foreign = append(foreign, scan.String())
+		}
+		summary += fmt.Sprintf(", %v stickers from other cars (%v)", len(s.Foreign), strings.Join(foreign, ", "))
+	}
+	return summary
+}
+
+// Alert is a warning for the scorers, such as a sticker from another car
+// scanned while counting a card.
+type Alert struct {
+	ID           int
+	Time         time.Time
+	Scanner      int
+	Car          int
+	Code         string
+	Message      string
+	Acknowledged bool
+}
+
+// sessionScan records a sticker scan in the scanner's open session. It
+// returns an alert if the sticker belongs to a different car. It must be
+// called with mu held.
+func (c *CountData) sessionScan(scan barcode.Scan, scanner int) *Alert {
+	session, ok := c.sessions[scanner]
+	if !ok {
+		return nil
+	}
+	if scan.Car == session.Car {
+		session.Scans = append(session.Scans, scan)
+		return nil
+	}
+	session.Foreign = append(session.Foreign, scan)
+	return c.newAlert(scanner, session.Car, scan.Code,
+		fmt.Sprintf("Sticker %v is from car %v but scanner %v is counting car %v", scan.String(), scan.Car, scanner, session.Car))
+}
+
+// newAlert records an alert. It must be called with mu held.
+func (c *CountData) newAlert(scanner int, car int, code string, message string) *Alert {
+	c.lastAlert++
+	alert := Alert{
+		ID:      c.lastAlert,
+		Time:    time.Now(),
+		Scanner: scanner,
+		Car:     car,
+		Code:    code,
+		Message: message,
+	}
+	c.alerts = append(c.alerts, alert)
+	log.Printf("[%v]ALERT: %v\n", scanner, message)
+	return &alert
+}
+
+// sessionCar opens or closes a session for a CA scan. Scanning the car being
+// counted closes its session; scanning another car closes the open session
+// and starts a new one. It must be called with mu held.
+func (c *CountData) sessionCar(car int, scanner int) {
+	session, ok := c.sessions[scanner]
+	if ok {
+		c.closeSession(scanner)
+		if session.Car == car {
+			return
+		}
+	}
+	c.lastSession++
+	c.sessions[scanner] = &Session{
+		ID:      c.lastSession,
+		Scanner: scanner,
+		Car:     car,
+		Start:   time.Now(),
+	}
+	log.Printf("[%v]Counting car %v\n", scanner, car)
+}
+
+// closeSession ends the scanner's open session and logs its summary. It must
+// be called with mu held.
+func (c *CountData) closeSession(scanner int) {
+	session, ok := c.sessions[scanner]
+	if !ok {
+		return
+	}
+	delete(c.sessions, scanner)
+	session.End = time.Now()
+	c.closedSessions = append(c.closedSessions, *session)
+	log.Printf("[%v]Finished counting: %v\n", scanner, session.Summary(c.event))
+}
+
+// CloseSession ends the scanner's open session.
+func (c *CountData) CloseSession(scanner int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closeSession(scanner)
+}
+
+// Sessions returns the open sessions followed by the closed ones, most
+// recent first.
+func (c *CountData) Sessions() []Session {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	sessions := make([]Session, 0, len(c.sessions)+len(c.closedSessions))
+	for scanner := -1; scanner < ScannerMax; scanner++ {
+		if session, ok := c.sessions[scanner]; ok {
+			sessions = append(sessions, *session)
+		}
+	}
+	for i := len(c.closedSessions) - 1; i >= 0; i-- {
+		sessions = append(sessions, c.closedSessions[i])
+	}
+	return sessions
+}
+
+// Alerts returns the alerts that have not been acknowledged, oldest first.
+func (c *CountData) Alerts() []Alert {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	alerts := make([]Alert, 0)
+	for _, alert := range c.alerts {
+		if !alert.Acknowledged {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts
+}
+
+// AcknowledgeAlert removes an alert from the list of alerts to show.
+func (c *CountData) AcknowledgeAlert(id int) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.alerts {
+		if c.alerts[i].ID == id {
+			c.alerts[i].Acknowledged = true
+			return nil
+		}
+	}
+	return fmt.Errorf("no alert %v", id)
+}
+
+// alert passes an alert to AlertFunc if one is set. It must be called
+// without holding mu.
+func (c *CountData) alert(alert *Alert) {
+	if alert != nil && c.AlertFunc != nil {
+		c.AlertFunc(*alert)
+	}
+}
@@ -0,0 +1,26 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+func TestSessionCounted(t *testing.T) {
+	ev := config.Default()
+	ev.Clues = 20
+	ev.Emergencies = 10
+	s := Session{Car: 5, Scans: []barcode.Scan{
+		{Car: 5, Kind: barcode.KindClue, Index: 1},
+		{Car: 5, Kind: barcode.KindClue, Index: 2},
+		{Car: 5, Kind: barcode.KindClue, Index: 20},
+		{Car: 5, Kind: barcode.KindEmergency, Index: 10},
+		// a sticker beyond the event, scanned before the event changed
+		{Car: 5, Kind: barcode.KindClue, Index: 26},
+	}}
+	clues, emergencies := s.Counted(ev)
+	if clues != "t-b" || emergencies != "10" {
+		t.Errorf("Counted() = %q, %q; want %q, %q", clues, emergencies, "t-b", "10")
+	}
+}
@@ -0,0 +1,341 @@
+// Package state holds the count of clue and emergency stickers for every
+// car and the operations that change it.
+//
+// The count is a matrix with a row per car. Column 0 records that the car
+// has been scanned, columns 1 to 26 are emergencies 1 to 26 and columns 27
+// to 52 are clues A to Z. A true value means the sticker is still on the
+// car's card, so the emergency was not opened or the clue was not visited.
+package state
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+// constants that effect the operation of the program
+const CarMax = 100
+const ClueNum = 26
+const ScannerMax = 20
+const CarStateFile = "carstate.json"
+const TimeStateFile = "timestate.json"
+const ProblemStateFile = "problems.json"
+const ScanStateFile = "scans.json"
+const VerifyStateFile = "verify.json"
+const LockStateFile = "locks.json"
+const EditStateFile = "edits.json"
+const TimelineStateFile = "timeline.json"
+const PrintCheckStateFile = "printcheck.json"
+
+const EmergencyOffset = 0 // emergencies are first in the matrix
+const ClueOffset = EmergencyOffset + ClueNum
+const TotalCol = (ClueNum * 2) + 1 + EmergencyOffset
+
+// Commands for the CA barcode set with the -command flag. CommandVerify
+// counts like CommandCount but records a second count for verification.
+// CommandPrintCheck checks every scan against the sticker cards instead of
+// counting it.
+const (
+	CommandCount      = "count"
+	CommandCheckIn    = "checkin"
+	CommandCheckOut   = "checkout"
+	CommandVerify     = "verify"
+	CommandPrintCheck = "printcheck"
+)
+
+// Matrix is the sticker matrix for every car.
+type Matrix [CarMax][TotalCol]bool
+
+type CarTime struct {
+	CheckOut time.Time
+	CheckIn  time.Time
+}
+
+type CarData struct {
+	CarNum        int
+	Scanned       bool
+	Emergencies   int
+	Clues         int
+	EmergencyList string
+	ClueList      string
+	ScanTime      time.Time
+	// Duplicates is the number of stickers scanned more than once and
+	// RapidRepeats the number of repeated reads inside the debounce window.
+	Duplicates   int
+	RapidRepeats int
+	// Status is CarNotReturned, CarInProgress, CarCounted or CarLocked.
+	Status string
+	Lock   CarLock
+	// Edited is set if the count has been changed by hand.
+	Edited bool
+	// Score is the car's score under the event's scoring and Rank its
+	// place among the counted cars, or 0 if it has not been counted.
+	Score int
+	Rank  int
+}
+
+type TallyData struct {
+	TotalClues         int
+	CountedClues       int
+	TotalEmergencies   int
+	CountedEmergencies int
+	LastSaved          time.Time
+}
+
+type ScannerData struct {
+	ScannerNum   int
+	ScanCount    int
+	LastScanTime time.Time
+	// Verifying is set while the scanner records a second count.
+	Verifying bool
+	// PrintChecking is set while the scanner checks printed stickers.
+	PrintChecking bool
+}
+
+// Stickers lists the stickers still on a car's card. Index 0 is clue A or
+// emergency 1.
+type Stickers struct {
+	CarNum      int
+	Clues       [ClueNum]bool
+	Emergencies [ClueNum]bool
+	// Version is the car's version when the stickers were read. Setting
+	// stickers with a Version other than 0 fails if the car has changed
+	// since.
+	Version int
+}
+
+// CountData is the counting state shared by the scanners and the web
+// interface. It is safe for concurrent use.
+type CountData struct {
+	Debug bool
+	// Command selects what a CA barcode does: CommandCount,
+	// CommandCheckIn, CommandCheckOut, CommandVerify or CommandPrintCheck.
+	Command string
+	// SaveFunc is called to save the data when a SAVE, QUIT or global CLEAR
+	// barcode is scanned.
+	SaveFunc func()
+	// ExportFunc is called instead of SaveFunc when a SAVE barcode selects
+	// an export format of the event, for example 0-SAVE-2, to save and also
+	// write that format.
+	ExportFunc func(config.ExportFormat) error
+	// AlertFunc is called with each new alert, for example to make the
+	// scanner that raised it beep.
+	AlertFunc func(Alert)
+	// ChangeFunc is called with the car number after every scan, edit,
+	// clear or check-in or check-out time that changes a car, for example
+	// to forward the car to another station. It is called with the count
+	// locked, so it must not call back into the count.
+	ChangeFunc func(car int)
+
+	mu      sync.Mutex
+	event   config.Event
+	grammar *barcode.Grammar
+	thCount *Matrix
+	// verify is the second count of each car, with the same layout as
+	// thCount
+	verify   *Matrix
+	scanTime *[CarMax]time.Time
+	thTimes  *[CarMax]CarTime
+	// edited marks the cars changed by hand, and edits is their audit
+	// history
+	edited   *[CarMax]bool
+	edits    []Edit
+	lastEdit int
+	locks    *[CarMax]CarLock
+	versions *[CarMax]int
+	scanners *[ScannerMax]ScannerData
+	// stickerScans counts the scans of each sticker, by matrix column
+	stickerScans *[CarMax][TotalCol]StickerScan
+	lastSaved    time.Time
+	quit         bool
+
+	problems    []Problem
+	lastProblem int
+
+	// open counting sessions by scanner
+	sessions       map[int]*Session
+	closedSessions []Session
+	lastSession    int
+	alerts         []Alert
+	lastAlert      int
+
+	// undo history, oldest first
+	actions    []Action
+	lastAction int
+
+	timeline []TimelineEntry
+
+	// stations forwarding their changes, by name
+	replicas map[string]*replicaStation
+
+	// codes read while checking printed stickers, by PrintRead.Code
+	printReads map[string]*PrintRead
+}
+
+// New returns an empty count.
+func New() *CountData {
+	var c CountData
+	c.Command = CommandCount
+	c.event = config.Default()
+	c.grammar = barcode.DefaultGrammar()
+	c.thCount = new(Matrix)
+	c.verify = new(Matrix)
+	c.thTimes = new([CarMax]CarTime)
+	c.scanTime = new([CarMax]time.Time)
+	c.edited = new([CarMax]bool)
+	c.locks = new([CarMax]CarLock)
+	c.versions = new([CarMax]int)
+	for i := range c.versions {
+		c.versions[i] = 1
+	}
+	c.scanners = new([ScannerMax]ScannerData)
+	c.stickerScans = new([CarMax][TotalCol]StickerScan)
+	c.sessions = make(map[int]*Session)
+	c.printReads = make(map[string]*PrintRead)
+	for i := range c.scanners {
+		c.scanners[i].ScannerNum = i
+	}
+	return &c
+}
+
+// SetEvent sets the event configuration used to check scans. It returns an
+// error if the event has more cars, clues or emergencies than the count can
+// hold.
+func (c *CountData) SetEvent(ev config.Event) error {
+	if err := ev.Check(); err != nil {
+		return err
+	}
+	if ev.Cars >= CarMax {
+		return fmt.Errorf("event has %v cars but at most %v can be counted", ev.Cars, CarMax-1)
+	}
+	if ev.Clues > ClueNum || ev.Emergencies > ClueNum {
+		return fmt.Errorf("event has %v clues and %v emergencies but at most %v of each can be counted", ev.Clues, ev.Emergencies, ClueNum)
+	}
+	grammar, err := ev.Grammar()
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.event = ev
+	c.grammar = grammar
+	return nil
+}
+
+// Event returns the event configuration.
+func (c *CountData) Event() config.Event {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.event
+}
+
+// ValidCar reports whether car is a car number that can be counted.
+func ValidCar(car int) bool {
+	return car > 0 && car < CarMax
+}
+
+// Quit tells the scanners to stop reading.
+func (c *CountData) Quit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.quit = true
+}
+
+// Quitting reports whether the program is shutting down.
+func (c *CountData) Quitting() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.quit
+}
+
+// Matrix returns a copy of the sticker matrix.
+func (c *CountData) Matrix() Matrix {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.thCount
+}
+
+// CarTimes returns the check-out and check-in times of every car.
+func (c *CountData) CarTimes() [CarMax]CarTime {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return *c.thTimes
+}
+
+// LastSaved returns the time the data was last saved.
+func (c *CountData) LastSaved() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastSaved
+}
+
+// SetSaved records that the data has just been saved.
+func (c *CountData) SetSaved() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastSaved = time.Now()
+}
+
+// ScannerRead records that a scanner has read data.
+func (c *CountData) ScannerRead(scanner int) {
+	if scanner < 0 || scanner >= ScannerMax {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanners[scanner].LastScanTime = time.Now()
+}
+
+// ScannerCounted records that a scanner has read a valid code.
+func (c *CountData) ScannerCounted(scanner int) {
+	if scanner < 0 || scanner >= ScannerMax {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanners[scanner].ScanCount++
+}
+
+// Scanners returns the scanners that have read at least one code, are
+// recording a second count or are checking printed stickers.
+func (c *CountData) Scanners() []ScannerData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	scanners := make([]ScannerData, 0)
+	for i := 0; i < ScannerMax; i++ {
+		if c.scanners[i].ScanCount > 0 || c.scanners[i].Verifying || c.scanners[i].PrintChecking {
+			scanners = append(scanners, c.scanners[i])
+		}
+	}
+	return scanners
+}
+
+// Status logs the number of cars counted.
+func (c *CountData) Status() {
+	log.Printf("%v cars counted\n", c.CarsCounted())
+}
+
+// save calls SaveFunc if one is set. It must be called without holding mu.
+func (c *CountData) save() {
+	if c.SaveFunc != nil {
+		c.SaveFunc()
+	}
+}
+
+// export calls ExportFunc with export format n of the event, numbered from
+// 1. It must be called without holding mu.
+func (c *CountData) export(n int) error {
+	formats := c.Event().Exports
+	if n < 1 || n > len(formats) {
+		return fmt.Errorf("no export format %v: the event has %v", n, len(formats))
+	}
+	if c.ExportFunc == nil {
+		c.save()
+		return nil
+	}
+	return c.ExportFunc(formats[n-1])
+}
@@ -0,0 +1,14 @@
+package state
+
+import "testing"
+
+// scanCodes processes barcodes as if read by scanner, and fails the test if
+// any is rejected.
+func scanCodes(t *testing.T, c *CountData, scanner int, codes ...string) {
+	t.Helper()
+	for _, code := range codes {
+		if err := c.Process(code, scanner); err != nil {
+			t.Fatalf("Process(%q): %v", code, err)
+		}
+	}
+}
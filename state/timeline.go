@@ -0,0 +1,62 @@
+package state
+
+import (
+	"sort"
+	"time"
+)
+
+// Timeline entry kinds
+const (
+	TimelineScan     = "scan"
+	TimelineRepeat   = "rapid repeat"
+	TimelineSecond   = "second count"
+	TimelineCar      = "car"
+	TimelineCheckIn  = "check-in"
+	TimelineCheckOut = "check-out"
+	TimelineEdit     = "edit"
+	TimelineClear    = "clear"
+	TimelineClearAll = "clear all"
+	TimelineUndo     = "undo"
+	TimelineLock     = "lock"
+	TimelineUnlock   = "unlock"
+	TimelineReplica  = "replicated"
+)
+
+// TimelineEntry is one scan or change of a car. Scanner is the scanner that
+// read the code, or ScannerWeb; By names the scorer for changes made from
+// the web interface.
+type TimelineEntry struct {
+	Time    time.Time
+	Car     int
+	Scanner int
+	By      string
+	Kind    string
+	Code    string
+	Detail  string
+}
+
+// addTimeline adds an entry to the timeline. Car 0 is used for the global
+// CLEAR. It must be called with mu held.
+func (c *CountData) addTimeline(entry TimelineEntry) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	c.timeline = append(c.timeline, entry)
+}
+
+// CarTimeline returns every scan and change of a car, including global
+// clears, oldest first.
+func (c *CountData) CarTimeline(car int) []TimelineEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entries := make([]TimelineEntry, 0)
+	for _, entry := range c.timeline {
+		if entry.Car == car || entry.Car == 0 && entry.Kind == TimelineClearAll {
+			entries = append(entries, entry)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
@@ -0,0 +1,215 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+// UndoMax is the number of actions kept for undo.
+const UndoMax = 200
+
+// Action kinds
+const (
+	ActionScan     = "scan"
+	ActionEdit     = "edit"
+	ActionClear    = "clear"
+	ActionClearAll = "clear all"
+	ActionImport   = "import"
+	ActionMerge    = "merge"
+	ActionReplica  = "replica"
+)
+
+// Action is a change to the count that can be undone.
+type Action struct {
+	ID      int
+	Kind    string
+	Scanner int
+	// Car is the car changed, or 0 for the global CLEAR, an import, a
+	// merge or changes forwarded by another station.
+	Car         int
+	Time        time.Time
+	Description string
+	Undone      bool
+
+	// the cars as they were before the action
+	before []carSnapshot
+}
+
+// carSnapshot is everything an action can change for one car.
+type carSnapshot struct {
+	car      int
+	row      [TotalCol]bool
+	verify   [TotalCol]bool
+	scanTime time.Time
+	times    CarTime
+	scans    [TotalCol]StickerScan
+	edited   bool
+}
+
+// snapshot copies a car's count. It must be called with mu held.
+func (c *CountData) snapshot(car int) carSnapshot {
+	return carSnapshot{
+		car:      car,
+		row:      c.thCount[car],
+		verify:   c.verify[car],
+		scanTime: c.scanTime[car],
+		times:    c.thTimes[car],
+		scans:    c.stickerScans[car],
+		edited:   c.edited[car],
+	}
+}
+
+// restore puts back a car's count from a snapshot. The check-out and
+// check-in times are only put back if times is set, as only clears, merges
+// and forwarded changes change them. It must be called with mu held.
+func (c *CountData) restore(s carSnapshot, times bool) {
+	c.thCount[s.car] = s.row
+	c.verify[s.car] = s.verify
+	c.scanTime[s.car] = s.scanTime
+	c.stickerScans[s.car] = s.scans
+	c.edited[s.car] = s.edited
+	c.bump(s.car)
+	if times {
+		c.thTimes[s.car] = s.times
+	}
+}
+
+// record adds an action to the undo history before it changes cars. It must
+// be called with mu held.
+func (c *CountData) record(kind string, scanner int, car int, description string, cars []int) {
+	before := make([]carSnapshot, 0, len(cars))
+	for _, car := range cars {
+		s := c.snapshot(car)
+		if kind == ActionClearAll && s == (carSnapshot{car: car}) {
+			// nothing to restore
+			continue
+		}
+		before = append(before, s)
+	}
+	c.recordSnapshots(kind, scanner, car, description, before)
+}
+
+// recordSnapshots adds an action to the undo history that restores the
+// snapshots taken before it changed the cars. It must be called with mu
+// held.
+func (c *CountData) recordSnapshots(kind string, scanner int, car int, description string, before []carSnapshot) {
+	c.lastAction++
+	action := Action{
+		ID:          c.lastAction,
+		Kind:        kind,
+		Scanner:     scanner,
+		Car:         car,
+		Time:        time.Now(),
+		Description: description,
+		before:      before,
+	}
+	c.actions = append(c.actions, action)
+	if len(c.actions) > UndoMax {
+		c.actions = c.actions[len(c.actions)-UndoMax:]
+	}
+}
+
+// touches reports whether an action changed car.
+func (a *Action) touches(car int) bool {
+	for _, s := range a.before {
+		if s.car == car {
+			return true
+		}
+	}
+	return false
+}
+
+// History returns the actions that can still be undone, most recent first.
+func (c *CountData) History() []Action {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	history := make([]Action, 0)
+	for i := len(c.actions) - 1; i >= 0; i-- {
+		if !c.actions[i].Undone {
+			history = append(history, c.actions[i])
+		}
+	}
+	return history
+}
+
+// LastAction returns the last action of a scanner, or of a car if car is
+// not 0, that can be undone.
+func (c *CountData) LastAction(scanner int, car int) (Action, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if i := c.lastActionIndex(scanner, car); i >= 0 {
+		return c.actions[i], true
+	}
+	return Action{}, false
+}
+
+// lastActionIndex returns the index in actions of the last action of a
+// scanner, or of a car if car is not 0, or -1 if there is none. It must be
+// called with mu held.
+func (c *CountData) lastActionIndex(scanner int, car int) int {
+	for i := len(c.actions) - 1; i >= 0; i-- {
+		a := &c.actions[i]
+		if a.Undone {
+			continue
+		}
+		if car != 0 && a.touches(car) || car == 0 && a.Scanner == scanner {
+			return i
+		}
+	}
+	return -1
+}
+
+// UndoScanner undoes the last action of a scanner.
+func (c *CountData) UndoScanner(scanner int) (Action, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.lastActionIndex(scanner, 0)
+	if i < 0 {
+		return Action{}, fmt.Errorf("nothing to undo for scanner %v", scanner)
+	}
+	return c.undo(i, scanner)
+}
+
+// UndoCar undoes the last action that changed a car. It is recorded as an
+// undo from the web interface; the UNDO barcode uses undoCarFrom.
+func (c *CountData) UndoCar(car int) (Action, error) {
+	return c.undoCarFrom(car, ScannerWeb)
+}
+
+func (c *CountData) undoCarFrom(car int, scanner int) (Action, error) {
+	if !ValidCar(car) {
+		return Action{}, fmt.Errorf("invalid car %v", car)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i := c.lastActionIndex(0, car)
+	if i < 0 {
+		return Action{}, fmt.Errorf("nothing to undo for car %v", car)
+	}
+	return c.undo(i, scanner)
+}
+
+// undo restores the cars changed by actions[i] for scanner. An action can
+// only be undone while it is the last change to each of its cars, so later
+// scans and edits are never lost. It must be called with mu held.
+func (c *CountData) undo(i int, scanner int) (Action, error) {
+	a := &c.actions[i]
+	for _, s := range a.before {
+		if err := c.checkUnlocked(s.car); err != nil {
+			return *a, err
+		}
+		for j := i + 1; j < len(c.actions); j++ {
+			if !c.actions[j].Undone && c.actions[j].touches(s.car) {
+				return *a, fmt.Errorf("cannot undo %q: car %v has been changed since", a.Description, s.car)
+			}
+		}
+	}
+	for _, s := range a.before {
+		c.restore(s, a.Kind == ActionClear || a.Kind == ActionClearAll || a.Kind == ActionMerge || a.Kind == ActionReplica)
+		c.addTimeline(TimelineEntry{Car: s.car, Scanner: scanner, Kind: TimelineUndo, Detail: a.Description})
+	}
+	a.Undone = true
+	log.Printf("[%v]Undone: %v\n", a.Scanner, a.Description)
+	return *a, nil
+}
@@ -0,0 +1,105 @@
+package state
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// checkUndo undoes the last action of car 7 and checks that it is back to
+// before, as a new version.
+func checkUndo(t *testing.T, c *CountData, kind string, before [TotalCol]bool) {
+	t.Helper()
+	version := c.CarVersion(7)
+	action, err := c.UndoCar(7)
+	if err != nil {
+		t.Fatalf("UndoCar(7): %v", err)
+	}
+	if action.Kind != kind || !action.Undone {
+		t.Errorf("UndoCar(7) = %+v; want a %v action undone", action, kind)
+	}
+	if got := c.Matrix()[7]; got != before {
+		t.Errorf("car 7 after undoing %v = %v; want %v", kind, got, before)
+	}
+	if c.CarVersion(7) <= version {
+		t.Errorf("undoing %v left car 7 at version %v; want it bumped from %v", kind, c.CarVersion(7), version)
+	}
+}
+
+func TestUndoScan(t *testing.T) {
+	c := New()
+	before := c.Matrix()[7]
+	version := c.CarVersion(7)
+	scanCodes(t, c, 1, "7-CL-A")
+	if c.CarVersion(7) <= version {
+		t.Errorf("scan left car 7 at version %v", c.CarVersion(7))
+	}
+	// a rapid repeat changes nothing, so it is not a separate action
+	scanCodes(t, c, 1, "7-CL-A")
+	if history := c.History(); len(history) != 1 {
+		t.Errorf("History() = %+v; want one scan", history)
+	}
+	checkUndo(t, c, ActionScan, before)
+	if scans := c.StickerScans(7); len(scans) != 0 {
+		t.Errorf("StickerScans(7) after undo = %+v; want none", scans)
+	}
+	if _, err := c.UndoCar(7); err == nil {
+		t.Error("UndoCar(7) with nothing left to undo did not fail")
+	}
+}
+
+func TestUndoEdit(t *testing.T) {
+	c := New()
+	scanCodes(t, c, 1, "7-CL-A")
+	before := c.Matrix()[7]
+	stickers := c.Stickers(7)
+	stickers.Clues[1] = true
+	if err := c.SetStickers(stickers, Editor{User: "test"}); err != nil {
+		t.Fatal(err)
+	}
+	checkUndo(t, c, ActionEdit, before)
+	if c.Car(7).Edited {
+		t.Error("car 7 still marked edited after undoing the edit")
+	}
+	// the page loaded before the undo is out of date
+	if err := c.SetStickers(stickers, Editor{}); !errors.Is(err, ErrStale) {
+		t.Errorf("SetStickers with the version before the undo = %v; want %v", err, ErrStale)
+	}
+}
+
+func TestUndoClear(t *testing.T) {
+	c := New()
+	scanCodes(t, c, 1, "7-CL-A", "7-CL-B", "7-EM-3")
+	before := c.Matrix()[7]
+	scans := c.StickerScans(7)
+	if err := c.ClearCar(7); err != nil {
+		t.Fatal(err)
+	}
+	if c.Matrix()[7] != ([TotalCol]bool{}) {
+		t.Fatalf("car 7 after clearing = %v", c.Matrix()[7])
+	}
+	checkUndo(t, c, ActionClear, before)
+	if got := c.StickerScans(7); len(got) != len(scans) {
+		t.Errorf("StickerScans(7) after undoing the clear = %+v; want %+v", got, scans)
+	}
+}
+
+func TestUndoOrder(t *testing.T) {
+	c := New()
+	scanCodes(t, c, 1, "7-CL-A")
+	scanCodes(t, c, 2, "7-CL-B")
+	// scanner 1's scan cannot be undone without losing scanner 2's
+	if _, err := c.UndoScanner(1); err == nil {
+		t.Error("UndoScanner(1) under a later scan did not fail")
+	}
+	if _, err := c.UndoScanner(2); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UndoScanner(1); err != nil {
+		t.Fatal(err)
+	}
+	if row := c.Matrix()[7]; row[stickerColumn(barcode.KindClue, 1)] || row[stickerColumn(barcode.KindClue, 2)] {
+		t.Errorf("car 7 after undoing both scans = %v", row)
+	}
+}
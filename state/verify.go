@@ -0,0 +1,214 @@
+package state
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// Counts compared by the verification report
+const (
+	CountPrimary = "primary"
+	CountSecond  = "second"
+)
+
+// VerifySticker is a sticker the primary and second counts disagree on.
+// Sticker is the letter of a clue or the number of an emergency. Primary and
+// Second are true if the sticker is on the card in that count.
+type VerifySticker struct {
+	Code    string
+	Kind    barcode.Kind
+	Index   int
+	Sticker string
+	Primary bool
+	Second  bool
+}
+
+// VerifyCar compares the primary and second counts of a car.
+type VerifyCar struct {
+	Car                int
+	PrimaryClues       int
+	SecondClues        int
+	PrimaryEmergencies int
+	SecondEmergencies  int
+	Discrepancies      []VerifySticker
+}
+
+// Matches reports whether both counts agree.
+func (v VerifyCar) Matches() bool {
+	return len(v.Discrepancies) == 0
+}
+
+// SetVerifying puts a scanner in verification mode, in which its scans are
+// recorded as a second count kept apart from the primary count. It ends the
+// scanner's print check.
+func (c *CountData) SetVerifying(scanner int, on bool) {
+	if scanner < 0 || scanner >= ScannerMax {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.scanners[scanner].Verifying = on
+	if on {
+		c.scanners[scanner].PrintChecking = false
+	}
+	log.Printf("[%v]Verification mode: %v\n", scanner, on)
+}
+
+// verifying reports whether a scanner's scans go to the second count. It
+// must be called with mu held.
+func (c *CountData) verifying(scanner int) bool {
+	if c.Command == CommandVerify {
+		return true
+	}
+	return scanner >= 0 && scanner < ScannerMax && c.scanners[scanner].Verifying
+}
+
+// VerifyReport compares the second count of every car that has one with its
+// primary count.
+func (c *CountData) VerifyReport() []VerifyCar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	report := make([]VerifyCar, 0)
+	for car := 1; car < CarMax; car++ {
+		if !c.verify[car][0] {
+			continue
+		}
+		report = append(report, c.verifyCar(car))
+	}
+	return report
+}
+
+// CarVerification compares the second count of a car with its primary count.
+func (c *CountData) CarVerification(car int) VerifyCar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.verifyCar(car)
+}
+
+func (c *CountData) verifyCar(car int) VerifyCar {
+	v := VerifyCar{Car: car}
+	for i := 1; i < TotalCol; i++ {
+		primary := c.thCount[car][i]
+		second := c.verify[car][i]
+		kind := barcode.KindEmergency
+		index := i - EmergencyOffset
+		if i > ClueOffset {
+			kind = barcode.KindClue
+			index = i - ClueOffset
+		}
+		if kind == barcode.KindClue && index > c.event.Clues || kind == barcode.KindEmergency && index > c.event.Emergencies {
+			// not a sticker of this event
+			continue
+		}
+		if kind == barcode.KindClue {
+			v.PrimaryClues += btoi(!primary)
+			v.SecondClues += btoi(!second)
+		} else {
+			v.PrimaryEmergencies += btoi(!primary)
+			v.SecondEmergencies += btoi(!second)
+		}
+		if primary == second {
+			continue
+		}
+		scan := barcode.Scan{Car: car, Kind: kind, Index: index}
+		sticker := strconv.Itoa(index)
+		if kind == barcode.KindClue {
+			sticker = scan.Clue()
+		}
+		v.Discrepancies = append(v.Discrepancies, VerifySticker{
+			Code:    scan.String(),
+			Kind:    kind,
+			Index:   index,
+			Sticker: sticker,
+			Primary: primary,
+			Second:  second,
+		})
+	}
+	return v
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// AcceptCount settles every discrepancy of a car in favour of one count,
+// CountPrimary or CountSecond, copying it over the other.
+func (c *CountData) AcceptCount(car int, count string, by Editor) error {
+	if !ValidCar(car) {
+		return fmt.Errorf("invalid car %v", car)
+	}
+	if count != CountPrimary && count != CountSecond {
+		return fmt.Errorf("unknown count %q", count)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.verify[car][0] {
+		return fmt.Errorf("car %v has no second count", car)
+	}
+	if err := c.checkUnlocked(car); err != nil {
+		return err
+	}
+	description := fmt.Sprintf("Accept the %v count of car %v", count, car)
+	c.record(ActionEdit, ScannerWeb, car, description, []int{car})
+	before := c.thCount[car]
+	if count == CountPrimary {
+		c.verify[car] = c.thCount[car]
+	} else {
+		c.thCount[car] = c.verify[car]
+	}
+	c.verify[car][0] = true
+	c.thCount[car][0] = true
+	c.bump(car)
+	c.audit(car, by, description, before)
+	return nil
+}
+
+// ReconcileSticker settles one sticker of a car, setting it in both counts.
+// present is true if the sticker is on the card.
+func (c *CountData) ReconcileSticker(car int, kind barcode.Kind, index int, present bool, by Editor) error {
+	if !ValidCar(car) {
+		return fmt.Errorf("invalid car %v", car)
+	}
+	if !kind.IsSticker() {
+		return fmt.Errorf("invalid sticker %v %v", kind, index)
+	}
+	scan := barcode.Scan{Car: car, Kind: kind, Index: index}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err := scan.Validate(c.event.Limits()); err != nil {
+		return err
+	}
+	if !c.verify[car][0] {
+		return fmt.Errorf("car %v has no second count", car)
+	}
+	if err := c.checkUnlocked(car); err != nil {
+		return err
+	}
+	description := fmt.Sprintf("Reconcile %v", scan)
+	c.record(ActionEdit, ScannerWeb, car, description, []int{car})
+	before := c.thCount[car]
+	col := stickerColumn(kind, index)
+	c.thCount[car][col] = present
+	c.verify[car][col] = present
+	c.thCount[car][0] = true
+	c.bump(car)
+	c.audit(car, by, description, before)
+	return nil
+}
+
+// ClearVerification removes the second count of a car.
+func (c *CountData) ClearVerification(car int) {
+	if !ValidCar(car) {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.verify[car] = [TotalCol]bool{}
+	log.Printf("Car %v second count cleared", car)
+}
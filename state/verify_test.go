@@ -0,0 +1,44 @@
+package state
+
+import (
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/config"
+)
+
+func TestVerifyEventLimits(t *testing.T) {
+	c := New()
+	ev := config.Default()
+	ev.Clues = 20
+	ev.Emergencies = 10
+	if err := c.SetEvent(ev); err != nil {
+		t.Fatal(err)
+	}
+	scanCodes(t, c, 1, "5-CL-A", "5-CL-B", "5-EM-3")
+	c.SetVerifying(2, true)
+	scanCodes(t, c, 2, "5-CL-A", "5-EM-3")
+
+	v := c.CarVerification(5)
+	want := VerifyCar{Car: 5, PrimaryClues: 18, SecondClues: 19, PrimaryEmergencies: 9, SecondEmergencies: 9}
+	if v.PrimaryClues != want.PrimaryClues || v.SecondClues != want.SecondClues ||
+		v.PrimaryEmergencies != want.PrimaryEmergencies || v.SecondEmergencies != want.SecondEmergencies {
+		t.Errorf("CarVerification(5) = %+v; want the counts of %+v", v, want)
+	}
+	if len(v.Discrepancies) != 1 || v.Discrepancies[0].Code != "5-CL-B" {
+		t.Errorf("CarVerification(5) discrepancies = %+v; want only 5-CL-B", v.Discrepancies)
+	}
+
+	if err := c.ReconcileSticker(5, barcode.KindClue, 21, true, Editor{}); err == nil {
+		t.Error("ReconcileSticker of clue 21 in a 20 clue event did not fail")
+	}
+	if err := c.ReconcileSticker(5, barcode.KindEmergency, 11, true, Editor{}); err == nil {
+		t.Error("ReconcileSticker of emergency 11 in a 10 emergency event did not fail")
+	}
+	if err := c.ReconcileSticker(5, barcode.KindClue, 2, true, Editor{}); err != nil {
+		t.Fatal(err)
+	}
+	if v := c.CarVerification(5); !v.Matches() {
+		t.Errorf("CarVerification(5) after reconciling = %+v; want a match", v)
+	}
+}
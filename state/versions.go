@@ -0,0 +1,76 @@
+package state
+
+import (
+	"errors"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// ErrStale is wrapped by the error SetStickers returns when the car has
+// changed since the stickers were read.
+var ErrStale = errors.New("changed since it was loaded")
+
+// bump records that a car's count has changed. It must be called with mu
+// held.
+func (c *CountData) bump(car int) {
+	c.versions[car]++
+	c.changed(car)
+}
+
+// CarVersion returns the version of a car's count, which changes with every
+// scan, edit, clear or undo of the car.
+func (c *CountData) CarVersion(car int) int {
+	if !ValidCar(car) {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.versions[car]
+}
+
+// row returns the stickers as a matrix row.
+func (s Stickers) row() [TotalCol]bool {
+	var row [TotalCol]bool
+	copy(row[1+EmergencyOffset:1+EmergencyOffset+ClueNum], s.Emergencies[:])
+	copy(row[1+ClueOffset:1+ClueOffset+ClueNum], s.Clues[:])
+	return row
+}
+
+// diffRows lists the stickers of a car that differ between two rows.
+func diffRows(car int, before [TotalCol]bool, after [TotalCol]bool) []StickerChange {
+	changes := make([]StickerChange, 0)
+	for i := 1; i < TotalCol; i++ {
+		if before[i] == after[i] {
+			continue
+		}
+		scan := barcode.Scan{Car: car, Kind: barcode.KindEmergency, Index: i - EmergencyOffset}
+		if i > ClueOffset {
+			scan = barcode.Scan{Car: car, Kind: barcode.KindClue, Index: i - ClueOffset}
+		}
+		changes = append(changes, StickerChange{Code: scan.String(), Before: before[i], After: after[i]})
+	}
+	return changes
+}
+
+// DiffStickers lists the stickers that differ between two sets of stickers
+// of the same car.
+func DiffStickers(before Stickers, after Stickers) []StickerChange {
+	return diffRows(after.CarNum, before.row(), after.row())
+}
+
+// MergeStickers applies the changes made from original to edited on top of
+// current, so stickers changed by anyone else since original are kept.
+func MergeStickers(original Stickers, edited Stickers, current Stickers) Stickers {
+	merged := current
+	for i := range merged.Clues {
+		if edited.Clues[i] != original.Clues[i] {
+			merged.Clues[i] = edited.Clues[i]
+		}
+	}
+	for i := range merged.Emergencies {
+		if edited.Emergencies[i] != original.Emergencies[i] {
+			merged.Emergencies[i] = edited.Emergencies[i]
+		}
+	}
+	return merged
+}
@@ -0,0 +1,269 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/export"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// APIError is the body of every error response from the JSON API.
+type APIError struct {
+	Status int
+	Error  string
+}
+
+// ScanRequest is the body posted to the scans endpoint.
+type ScanRequest struct {
+	Code string
+}
+
+// ScanResult is returned by the scans endpoint for an accepted code.
+type ScanResult struct {
+	Code  string
+	Valid bool
+}
+
+// SaveResult is returned by the save endpoint.
+type SaveResult struct {
+	Saved     bool
+	LastSaved time.Time
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", " ")
+	if err := enc.Encode(v); err != nil {
+		log.Printf("Error writing JSON response: %v\n", err)
+	}
+}
+
+func writeJSONError(w http.ResponseWriter, status int, format string, a ...interface{}) {
+	writeJSON(w, status, APIError{Status: status, Error: fmt.Sprintf(format, a...)})
+}
+
+// apiCar parses the {car} route parameter. On failure it writes the error
+// response and returns false.
+func apiCar(w http.ResponseWriter, params routeParams) (int, bool) {
+	car, err := strconv.Atoi(params["car"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid car number %q", params["car"])
+		return 0, false
+	}
+	if !state.ValidCar(car) {
+		writeJSONError(w, http.StatusNotFound, "car %v does not exist. Cars are 1 to %v", car, state.CarMax-1)
+		return 0, false
+	}
+	return car, true
+}
+
+func (s *Server) apiGetCars(w http.ResponseWriter, req *http.Request, params routeParams) {
+	// index 0 of buildCarData is unused
+	writeJSON(w, http.StatusOK, s.count.BuildCarData()[1:])
+}
+
+func (s *Server) apiGetCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.Car(car))
+}
+
+func (s *Server) apiGetStickers(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.Stickers(car))
+}
+
+func (s *Server) apiPutStickers(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	var editData state.Stickers
+	dec := json.NewDecoder(req.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&editData); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid sticker data: %v", err)
+		return
+	}
+	if editData.CarNum != 0 && editData.CarNum != car {
+		writeJSONError(w, http.StatusBadRequest, "car number %v in body does not match car %v in path", editData.CarNum, car)
+		return
+	}
+	editData.CarNum = car
+	if err := s.count.SetStickers(editData, editor(req)); err != nil {
+		writeJSONError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.Stickers(car))
+}
+
+// LockRequest is the body posted to lock or unlock a car.
+type LockRequest struct {
+	By string
+}
+
+func (s *Server) apiPostLock(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.apiLock(w, req, params, s.count.Lock)
+}
+
+func (s *Server) apiPostUnlock(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.apiLock(w, req, params, s.count.Unlock)
+}
+
+// apiLock locks or unlocks a car with lock and returns the car.
+func (s *Server) apiLock(w http.ResponseWriter, req *http.Request, params routeParams, lock func(int, string) error) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	var body LockRequest
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid body: %v", err)
+			return
+		}
+	}
+	by := state.Editor{User: body.By, RemoteAddr: req.RemoteAddr}
+	if err := lock(car, by.String()); err != nil {
+		writeJSONError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.Car(car))
+}
+
+func (s *Server) apiGetHistory(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.History())
+}
+
+func (s *Server) apiPostUndoCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	action, err := s.count.UndoCar(car)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, action)
+}
+
+func (s *Server) apiPostUndoScanner(w http.ResponseWriter, req *http.Request, params routeParams) {
+	scanner, err := strconv.Atoi(params["scanner"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid scanner %q", params["scanner"])
+		return
+	}
+	action, err := s.count.UndoScanner(scanner)
+	if err != nil {
+		writeJSONError(w, http.StatusConflict, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, action)
+}
+
+func (s *Server) apiGetCarAudit(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.CarEdits(car))
+}
+
+func (s *Server) apiGetCarTimeline(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.CarTimeline(car))
+}
+
+func (s *Server) apiGetStickerScans(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.StickerScans(car))
+}
+
+func (s *Server) apiGetDuplicates(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.DuplicateReport())
+}
+
+func (s *Server) apiGetSessions(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.Sessions())
+}
+
+func (s *Server) apiGetAlerts(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.Alerts())
+}
+
+func (s *Server) apiPostAckAlert(w http.ResponseWriter, req *http.Request, params routeParams) {
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid alert id %q", params["id"])
+		return
+	}
+	if err := s.count.AcknowledgeAlert(id); err != nil {
+		writeJSONError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.Alerts())
+}
+
+func (s *Server) apiGetTally(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.Tally())
+}
+
+func (s *Server) apiGetScanners(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.Scanners())
+}
+
+// apiPostScan processes a barcode as if it had been read by a scanner.
+func (s *Server) apiPostScan(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var scan ScanRequest
+	if err := json.NewDecoder(req.Body).Decode(&scan); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid scan: %v", err)
+		return
+	}
+	code := strings.TrimSpace(scan.Code)
+	if len(code) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "no code to scan")
+		return
+	}
+	if err := s.count.Process(code, state.ScannerWeb); err != nil {
+		s.count.Reject(code, state.ScannerWeb, err)
+		writeJSONError(w, http.StatusUnprocessableEntity, "%v", err)
+		return
+	}
+	log.Printf("Code %v scanned from %v\n", code, req.RemoteAddr)
+	writeJSON(w, http.StatusOK, ScanResult{Code: code, Valid: true})
+}
+
+func (s *Server) apiPostSave(w http.ResponseWriter, req *http.Request, params routeParams) {
+	filename, err := export.Save(s.count)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "save failed: %v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, SaveResult{Saved: len(filename) > 0, LastSaved: s.count.LastSaved()})
+}
+
+func (s *Server) apiGetExport(w http.ResponseWriter, req *http.Request, params routeParams) {
+	if !s.writeExport(w, req, false) {
+		writeJSONError(w, http.StatusBadRequest, "unknown export format %q", req.URL.Query().Get("format"))
+	}
+}
@@ -0,0 +1,192 @@
+package web
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+)
+
+// Barcode symbologies and image formats for the barcode endpoint
+const (
+	symbologyCode39  = "code39"
+	symbologyCode128 = "code128"
+	imagePNG         = "png"
+	imageSVG         = "svg"
+)
+
+// Barcode image limits. The quiet zone is the blank space either side of
+// the bars, in modules.
+const (
+	barcodeMaxLength = 80
+	barcodeQuietZone = 10
+	barcodeScale     = 2
+	barcodeMaxScale  = 10
+	barcodeHeight    = 60
+	barcodeMaxHeight = 600
+	barcodeCacheAge  = 24 * 60 * 60
+)
+
+// CommandBarcode is a command barcode shown on the dashboard.
+type CommandBarcode struct {
+	Label string
+	Code  string
+}
+
+// scanCode returns the code to show for scan, or "" if the event's barcode
+// layouts would not read it.
+func (s *Server) scanCode(scan barcode.Scan) string {
+	ev := s.count.Event()
+	grammar, err := ev.Grammar()
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	code, err := grammar.Code(scan, ev.Limits())
+	if err != nil {
+		log.Println(err)
+		return ""
+	}
+	return code
+}
+
+// commandBarcodes returns the command barcodes shown on the dashboard.
+func (s *Server) commandBarcodes() []CommandBarcode {
+	commands := []CommandBarcode{
+		{"Save", s.scanCode(barcode.Scan{Kind: barcode.KindSave})},
+		{"Status", s.scanCode(barcode.Scan{Kind: barcode.KindStatus})},
+	}
+	shown := commands[:0]
+	for _, c := range commands {
+		if len(c.Code) > 0 {
+			shown = append(shown, c)
+		}
+	}
+	return shown
+}
+
+// encodeBarcode returns the modules of code in the named symbology, Code 39
+// if it is empty.
+func encodeBarcode(code string, symbology string) ([]bool, error) {
+	if len(code) == 0 {
+		return nil, fmt.Errorf("no code given")
+	}
+	if len(code) > barcodeMaxLength {
+		return nil, fmt.Errorf("codes are limited to %v characters", barcodeMaxLength)
+	}
+	switch symbology {
+	case "", symbologyCode39:
+		return barcode.Code39(code)
+	case symbologyCode128:
+		return barcode.Code128(code)
+	}
+	return nil, fmt.Errorf("unknown barcode type %q: use %v or %v", symbology, symbologyCode39, symbologyCode128)
+}
+
+// queryInt returns the named query parameter as a number from 1 to max, or
+// def if it is missing.
+func queryInt(req *http.Request, name string, def int, max int) (int, error) {
+	s := req.URL.Query().Get(name)
+	if len(s) == 0 {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("%v must be a number from 1 to %v", name, max)
+	}
+	return n, nil
+}
+
+// getBarcode draws the code query parameter as a barcode image. The type
+// parameter chooses code39 (the default, as on the printed sheets) or
+// code128, format chooses png (the default) or svg, scale is the width of
+// a module in pixels and height the height of the bars.
+func (s *Server) getBarcode(w http.ResponseWriter, req *http.Request, params routeParams) {
+	q := req.URL.Query()
+	modules, err := encodeBarcode(q.Get("code"), q.Get("type"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	scale, err := queryInt(req, "scale", barcodeScale, barcodeMaxScale)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	height, err := queryInt(req, "height", barcodeHeight, barcodeMaxHeight)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var write func() error
+	switch q.Get("format") {
+	case "", imagePNG:
+		w.Header().Set("Content-Type", "image/png")
+		write = func() error {
+			return png.Encode(w, barcodeImage(modules, scale, height))
+		}
+	case imageSVG:
+		w.Header().Set("Content-Type", "image/svg+xml")
+		write = func() error {
+			return writeBarcodeSVG(w, modules, scale, height)
+		}
+	default:
+		http.Error(w, fmt.Sprintf("unknown image format %q: use %v or %v", q.Get("format"), imagePNG, imageSVG), http.StatusBadRequest)
+		return
+	}
+	// the image only depends on the URL
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%v", barcodeCacheAge))
+	if err := write(); err != nil {
+		log.Printf("Error writing barcode: %v\n", err)
+	}
+}
+
+// barcodeImage draws modules scale pixels wide and height pixels high, with
+// the quiet zone either side.
+func barcodeImage(modules []bool, scale int, height int) image.Image {
+	width := (len(modules) + 2*barcodeQuietZone) * scale
+	img := image.NewPaletted(image.Rect(0, 0, width, height), color.Palette{color.White, color.Black})
+	for i, bar := range modules {
+		if !bar {
+			continue
+		}
+		x := (barcodeQuietZone + i) * scale
+		for y := 0; y < height; y++ {
+			for dx := 0; dx < scale; dx++ {
+				img.SetColorIndex(x+dx, y, 1)
+			}
+		}
+	}
+	return img
+}
+
+// writeBarcodeSVG writes modules as an SVG image the same size as
+// barcodeImage would draw them, with a rectangle for each bar.
+func writeBarcodeSVG(w io.Writer, modules []bool, scale int, height int) error {
+	b := bufio.NewWriter(w)
+	width := (len(modules) + 2*barcodeQuietZone) * scale
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%v" height="%v" viewBox="0 0 %v %v" shape-rendering="crispEdges">`+"\n", width, height, width, height)
+	fmt.Fprintf(b, `<rect width="%v" height="%v" fill="white"/>`+"\n", width, height)
+	b.WriteString(`<path fill="black" d="`)
+	for i := 0; i < len(modules); {
+		if !modules[i] {
+			i++
+			continue
+		}
+		start := i
+		for i < len(modules) && modules[i] {
+			i++
+		}
+		fmt.Fprintf(b, "M%v 0h%vv%vh%vz", (barcodeQuietZone+start)*scale, (i-start)*scale, height, -(i-start)*scale)
+	}
+	b.WriteString(`"/>` + "\n</svg>\n")
+	return b.Flush()
+}
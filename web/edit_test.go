@@ -0,0 +1,127 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// editForm returns the form the edit page posts for stickers, loaded when
+// the car had the original stickers.
+func editForm(stickers state.Stickers, original state.Stickers) url.Values {
+	form := url.Values{}
+	form.Set("car", strconv.Itoa(stickers.CarNum))
+	form.Set("version", strconv.Itoa(original.Version))
+	form.Set("original", encodeStickers(original))
+	for i, present := range stickers.Clues {
+		if present {
+			form.Set(fmt.Sprintf("clue%v", i), "true")
+		}
+	}
+	for i, present := range stickers.Emergencies {
+		if present {
+			form.Set(fmt.Sprintf("emergency%v", i), "true")
+		}
+	}
+	return form
+}
+
+var (
+	hiddenInput  = regexp.MustCompile(`<input type="hidden" (?:id="\w+" )?name="(\w+)" value="(\w*)">`)
+	checkedInput = regexp.MustCompile(`name="((?:clue|emergency)\d+)" value="true"\s*checked>`)
+)
+
+// pageForm returns the form an edit page would post without changes.
+func pageForm(page string) url.Values {
+	form := url.Values{}
+	for _, m := range hiddenInput.FindAllStringSubmatch(page, -1) {
+		form.Set(m[1], m[2])
+	}
+	for _, m := range checkedInput.FindAllStringSubmatch(page, -1) {
+		form.Set(m[1], "true")
+	}
+	return form
+}
+
+func postForm(s *Server, path string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestEditConflict(t *testing.T) {
+	count := state.New()
+	s := New(count, "../templates")
+	original := count.Stickers(5)
+
+	// two scorers load the page and change different stickers
+	first := original
+	first.Clues[0] = true
+	second := original
+	second.Emergencies[2] = true
+	if w := postForm(s, "/updateCar", editForm(first, original)); w.Code != http.StatusSeeOther {
+		t.Fatalf("first edit = %v %v; want %v", w.Code, w.Body, http.StatusSeeOther)
+	}
+	w := postForm(s, "/updateCar", editForm(second, original))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("second edit = %v; want %v", w.Code, http.StatusConflict)
+	}
+	if got := count.Stickers(5); got.Emergencies[2] {
+		t.Error("stale edit was saved")
+	}
+
+	// the conflict page holds both changes, and saving it keeps both
+	form := pageForm(w.Body.String())
+	if form.Get("version") != strconv.Itoa(count.CarVersion(5)) {
+		t.Errorf("conflict page version = %q; want %v", form.Get("version"), count.CarVersion(5))
+	}
+	if w := postForm(s, "/updateCar", form); w.Code != http.StatusSeeOther {
+		t.Fatalf("merged edit = %v %v; want %v", w.Code, w.Body, http.StatusSeeOther)
+	}
+	if got := count.Stickers(5); !got.Clues[0] || !got.Emergencies[2] {
+		t.Errorf("car 5 after merging = %+v; want clue A and emergency 3", got)
+	}
+}
+
+func TestAPIEditConflict(t *testing.T) {
+	count := state.New()
+	s := New(count, "../templates")
+	put := func(stickers state.Stickers) *httptest.ResponseRecorder {
+		body, _ := json.Marshal(stickers)
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/cars/5/stickers", bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		return w
+	}
+	original := count.Stickers(5)
+	first := original
+	first.Clues[0] = true
+	second := original
+	second.Emergencies[2] = true
+	if w := put(first); w.Code != http.StatusOK {
+		t.Fatalf("first PUT = %v %v; want %v", w.Code, w.Body, http.StatusOK)
+	}
+	if w := put(second); w.Code != http.StatusConflict {
+		t.Fatalf("second PUT = %v %v; want %v", w.Code, w.Body, http.StatusConflict)
+	}
+
+	// merged onto the current version, the second edit is accepted
+	merged := state.MergeStickers(original, second, count.Stickers(5))
+	if w := put(merged); w.Code != http.StatusOK {
+		t.Fatalf("merged PUT = %v %v; want %v", w.Code, w.Body, http.StatusOK)
+	}
+	if got := count.Stickers(5); !got.Clues[0] || !got.Emergencies[2] {
+		t.Errorf("car 5 after merging = %+v; want clue A and emergency 3", got)
+	}
+}
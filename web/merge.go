@@ -0,0 +1,102 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// MergePageData is the data for merging another station's count.
+type MergePageData struct {
+	Title string
+	Error string
+	// Result is the result of a merge, shown once after it
+	Result *state.MergeResult
+}
+
+// MergeRequest is the body posted to merge other stations' counts.
+type MergeRequest struct {
+	Stations []state.Station
+}
+
+func (s *Server) getMerge(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.renderMerge(w, http.StatusOK, nil, nil)
+}
+
+// renderMerge renders the merge page with the result of a merge or an
+// error.
+func (s *Server) renderMerge(w http.ResponseWriter, status int, result *state.MergeResult, err error) {
+	pageData := MergePageData{Title: "Merge Stations", Result: result}
+	if err != nil {
+		pageData.Error = err.Error()
+	}
+	w.WriteHeader(status)
+	s.render(w, "merge.html", nil, pageData)
+}
+
+// formStationFile opens an uploaded state file, or returns nil if it was not
+// uploaded.
+func formStationFile(req *http.Request, name string) (multipart.File, error) {
+	f, _, err := req.FormFile(name)
+	if err == http.ErrMissingFile {
+		return nil, nil
+	}
+	return f, err
+}
+
+// postMerge merges the state files of another station uploaded from the
+// merge page. Only the car state file is required.
+func (s *Server) postMerge(w http.ResponseWriter, req *http.Request, params routeParams) {
+	name := strings.TrimSpace(req.FormValue("station"))
+	if len(name) == 0 {
+		name = "upload"
+	}
+	files := make(map[string]io.Reader)
+	for _, field := range []string{"carstate", "timestate", "timeline"} {
+		f, err := formStationFile(req, field)
+		if err != nil {
+			s.renderMerge(w, http.StatusBadRequest, nil, err)
+			return
+		}
+		if f != nil {
+			defer f.Close()
+			files[field] = f
+		}
+	}
+	if files["carstate"] == nil {
+		s.renderMerge(w, http.StatusBadRequest, nil, fmt.Errorf("no %v to merge", state.CarStateFile))
+		return
+	}
+	st, err := state.ReadStation(name, files["carstate"], files["timestate"], files["timeline"])
+	if err != nil {
+		s.renderMerge(w, http.StatusBadRequest, nil, err)
+		return
+	}
+	result, err := s.count.Merge([]state.Station{st}, editor(req))
+	if err != nil {
+		log.Printf("Error merging %v: %v\n", name, err)
+		s.renderMerge(w, http.StatusBadRequest, nil, err)
+		return
+	}
+	s.renderMerge(w, http.StatusOK, &result, nil)
+}
+
+func (s *Server) apiPostMerge(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var body MergeRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid merge: %v", err)
+		return
+	}
+	result, err := s.count.Merge(body.Stations, editor(req))
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
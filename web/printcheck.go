@@ -0,0 +1,103 @@
+package web
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// PrintCheckPageData is the data for the print check page. Last is the
+// code just checked from the page, if any.
+type PrintCheckPageData struct {
+	Title    string
+	Report   state.PrintCheckReport
+	Scanners []state.ScannerData
+	Last     *state.PrintRead
+}
+
+func (s *Server) getPrintCheck(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.renderPrintCheck(w, nil)
+}
+
+func (s *Server) renderPrintCheck(w http.ResponseWriter, last *state.PrintRead) {
+	var pageData PrintCheckPageData
+	pageData.Title = "Print Check"
+	pageData.Report = s.count.PrintCheckReport()
+	pageData.Scanners = s.count.Scanners()
+	pageData.Last = last
+	s.render(w, "printcheck.html", nil, pageData)
+}
+
+// postPrintCheck checks a code typed or read into the page, for scanners
+// that type into the browser rather than being attached to the counting
+// computer.
+func (s *Server) postPrintCheck(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	code := strings.TrimSpace(req.FormValue("code"))
+	if len(code) == 0 {
+		http.Redirect(w, req, "/printcheck", http.StatusSeeOther)
+		return
+	}
+	read := s.count.CheckPrint(code, state.ScannerWeb)
+	s.renderPrintCheck(w, &read)
+}
+
+func (s *Server) postPrintCheckScanner(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	scanner, err := strconv.Atoi(req.FormValue("scanner"))
+	if err == nil {
+		on, _ := strconv.ParseBool(req.FormValue("on"))
+		s.count.SetPrintChecking(scanner, on)
+	}
+	http.Redirect(w, req, "/printcheck", http.StatusSeeOther)
+}
+
+func (s *Server) postPrintCheckReset(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.count.ResetPrintCheck()
+	http.Redirect(w, req, "/printcheck", http.StatusSeeOther)
+}
+
+func (s *Server) apiGetPrintCheck(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.PrintCheckReport())
+}
+
+// apiPostPrintCheckScan checks a code against the sticker cards without
+// counting it.
+func (s *Server) apiPostPrintCheckScan(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var scan ScanRequest
+	if err := json.NewDecoder(req.Body).Decode(&scan); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid scan: %v", err)
+		return
+	}
+	code := strings.TrimSpace(scan.Code)
+	if len(code) == 0 {
+		writeJSONError(w, http.StatusBadRequest, "no code to check")
+		return
+	}
+	log.Printf("Code %v checked from %v\n", code, req.RemoteAddr)
+	writeJSON(w, http.StatusOK, s.count.CheckPrint(code, state.ScannerWeb))
+}
+
+func (s *Server) apiPostPrintCheckReset(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.count.ResetPrintCheck()
+	writeJSON(w, http.StatusOK, s.count.PrintCheckReport())
+}
+
+func (s *Server) apiPutScannerPrintChecking(w http.ResponseWriter, req *http.Request, params routeParams) {
+	scanner, err := strconv.Atoi(params["scanner"])
+	if err != nil || scanner < 0 || scanner >= state.ScannerMax {
+		writeJSONError(w, http.StatusBadRequest, "invalid scanner %q", params["scanner"])
+		return
+	}
+	var body struct{ PrintChecking bool }
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	s.count.SetPrintChecking(scanner, body.PrintChecking)
+	writeJSON(w, http.StatusOK, s.count.Scanners())
+}
@@ -0,0 +1,97 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// Problem resolutions
+const (
+	actionAssign  = "assign"
+	actionDismiss = "dismiss"
+	actionDamaged = "damaged"
+)
+
+// ProblemResolution is the body posted to resolve a rejected code. Car,
+// Kind ("CL" or "EM") and Sticker ("A" or "3") are only used to assign it.
+type ProblemResolution struct {
+	Action  string
+	Car     int
+	Kind    string
+	Sticker string
+}
+
+// resolveProblem assigns, dismisses or marks a rejected code as damaged.
+func (s *Server) resolveProblem(id int, res ProblemResolution) error {
+	switch res.Action {
+	case actionAssign:
+		code := fmt.Sprintf("%v-%v-%v", res.Car, strings.ToUpper(res.Kind), strings.TrimSpace(res.Sticker))
+		scan, err := barcode.Parse(code, s.count.Event().Limits())
+		if err != nil {
+			return err
+		}
+		return s.count.AssignProblem(id, scan)
+	case actionDismiss:
+		return s.count.DismissProblem(id)
+	case actionDamaged:
+		return s.count.MarkDamaged(id)
+	}
+	return fmt.Errorf("unknown action %q", res.Action)
+}
+
+func (s *Server) postResolveProblem(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	id, _ := strconv.Atoi(req.FormValue("id"))
+	car, _ := strconv.Atoi(req.FormValue("car"))
+	res := ProblemResolution{
+		Action:  req.FormValue("action"),
+		Car:     car,
+		Kind:    req.FormValue("kind"),
+		Sticker: req.FormValue("sticker"),
+	}
+	if err := s.resolveProblem(id, res); err != nil {
+		log.Printf("Error resolving problem %v: %v\n", id, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, req, "/#problems", http.StatusSeeOther)
+}
+
+func (s *Server) apiGetProblems(w http.ResponseWriter, req *http.Request, params routeParams) {
+	if req.URL.Query().Get("all") == "true" {
+		writeJSON(w, http.StatusOK, s.count.AllProblems())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.Problems())
+}
+
+func (s *Server) apiPostProblem(w http.ResponseWriter, req *http.Request, params routeParams) {
+	id, err := strconv.Atoi(params["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid problem id %q", params["id"])
+		return
+	}
+	var res ProblemResolution
+	if err := json.NewDecoder(req.Body).Decode(&res); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid resolution: %v", err)
+		return
+	}
+	if err := s.resolveProblem(id, res); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "%v", err)
+		return
+	}
+	for _, p := range s.count.AllProblems() {
+		if p.ID == id {
+			writeJSON(w, http.StatusOK, p)
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, state.Problem{ID: id})
+}
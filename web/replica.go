@@ -0,0 +1,57 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/awoodward/azth-scoringcount/replica"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// ReplicaRequest is the body a secondary station posts to forward the
+// counts of the cars changed there.
+type ReplicaRequest struct {
+	Station string
+	Cars    []state.ReplicaCar
+}
+
+// ReplicationStatus is returned by the replication endpoint. Forwarding is
+// set on a secondary station and Stations lists the secondary stations
+// forwarding to this one.
+type ReplicationStatus struct {
+	Forwarding *replica.Status
+	Stations   []state.ReplicaStation
+}
+
+// replicationStatus returns the forwarding status of the station.
+func (s *Server) replicationStatus() ReplicationStatus {
+	status := ReplicationStatus{Stations: s.count.ReplicaStations()}
+	if s.Replica != nil {
+		forwarding := s.Replica.Status()
+		status.Forwarding = &forwarding
+	}
+	return status
+}
+
+func (s *Server) apiPostReplicate(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var body ReplicaRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid replication: %v", err)
+		return
+	}
+	station := strings.TrimSpace(body.Station)
+	if len(station) == 0 {
+		station = req.RemoteAddr
+	}
+	result, err := s.count.ApplyReplica(station, body.Cars)
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) apiGetReplication(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.replicationStatus())
+}
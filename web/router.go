@@ -0,0 +1,162 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// routeParams holds the values of the {name} segments matched in a route
+// pattern.
+type routeParams map[string]string
+
+type handlerFunc func(w http.ResponseWriter, req *http.Request, params routeParams)
+
+type route struct {
+	method  string
+	pattern string
+	handler handlerFunc
+}
+
+// routes returns the route table for the web interface and the JSON API.
+// Patterns are matched segment by segment; a segment written as {name}
+// matches any value and is passed to the handler in routeParams.
+func (s *Server) routes() []route {
+	return []route{
+		// web pages
+		{http.MethodGet, "/", s.getDashboard},
+		{http.MethodGet, "/download", s.getDownload},
+		{http.MethodGet, "/downloads", s.getDownloads},
+		{http.MethodGet, "/save", s.getSave},
+		{http.MethodGet, "/saves", s.getSaves},
+		{http.MethodGet, "/saves/{id}/{file}", s.getSaveFile},
+		{http.MethodGet, "/saveDiff", s.getSaveDiff},
+		{http.MethodPost, "/import", s.postImport},
+		{http.MethodGet, "/merge", s.getMerge},
+		{http.MethodPost, "/merge", s.postMerge},
+		{http.MethodGet, "/car", s.getCar},
+		{http.MethodGet, "/edit", s.getEdit},
+		{http.MethodPost, "/updateCar", s.postUpdateCar},
+		{http.MethodGet, "/clearCar", s.getClearCar},
+		{http.MethodPost, "/lockCar", s.postLockCar},
+		{http.MethodPost, "/unlockCar", s.postUnlockCar},
+		{http.MethodPost, "/undo", s.postUndo},
+		{http.MethodGet, "/audit", s.getAudit},
+		{http.MethodPost, "/resolveProblem", s.postResolveProblem},
+		{http.MethodGet, "/duplicates", s.getDuplicates},
+		{http.MethodPost, "/ackAlert", s.postAckAlert},
+		{http.MethodPost, "/closeSession", s.postCloseSession},
+		{http.MethodGet, "/verify", s.getVerify},
+		{http.MethodPost, "/verify", s.postVerify},
+		{http.MethodPost, "/verifyScanner", s.postVerifyScanner},
+		{http.MethodGet, "/printcheck", s.getPrintCheck},
+		{http.MethodPost, "/printcheck", s.postPrintCheck},
+		{http.MethodPost, "/printCheckScanner", s.postPrintCheckScanner},
+		{http.MethodPost, "/printCheckReset", s.postPrintCheckReset},
+		{http.MethodGet, "/barcode", s.getBarcode},
+
+		// JSON API
+		{http.MethodGet, "/api/v1/cars", s.apiGetCars},
+		{http.MethodGet, "/api/v1/cars/{car}", s.apiGetCar},
+		{http.MethodGet, "/api/v1/cars/{car}/stickers", s.apiGetStickers},
+		{http.MethodPut, "/api/v1/cars/{car}/stickers", s.apiPutStickers},
+		{http.MethodGet, "/api/v1/cars/{car}/scans", s.apiGetStickerScans},
+		{http.MethodPost, "/api/v1/cars/{car}/lock", s.apiPostLock},
+		{http.MethodPost, "/api/v1/cars/{car}/unlock", s.apiPostUnlock},
+		{http.MethodPost, "/api/v1/cars/{car}/undo", s.apiPostUndoCar},
+		{http.MethodGet, "/api/v1/cars/{car}/audit", s.apiGetCarAudit},
+		{http.MethodGet, "/api/v1/cars/{car}/timeline", s.apiGetCarTimeline},
+		{http.MethodGet, "/api/v1/cars/{car}/verification", s.apiGetCarVerification},
+		{http.MethodPost, "/api/v1/cars/{car}/verification", s.apiPostCarVerification},
+		{http.MethodGet, "/api/v1/verification", s.apiGetVerification},
+		{http.MethodGet, "/api/v1/duplicates", s.apiGetDuplicates},
+		{http.MethodGet, "/api/v1/sessions", s.apiGetSessions},
+		{http.MethodGet, "/api/v1/alerts", s.apiGetAlerts},
+		{http.MethodPost, "/api/v1/alerts/{id}/ack", s.apiPostAckAlert},
+		{http.MethodGet, "/api/v1/tally", s.apiGetTally},
+		{http.MethodGet, "/api/v1/scanners", s.apiGetScanners},
+		{http.MethodPut, "/api/v1/scanners/{scanner}/verifying", s.apiPutScannerVerifying},
+		{http.MethodPut, "/api/v1/scanners/{scanner}/printchecking", s.apiPutScannerPrintChecking},
+		{http.MethodPost, "/api/v1/scanners/{scanner}/undo", s.apiPostUndoScanner},
+		{http.MethodGet, "/api/v1/history", s.apiGetHistory},
+		{http.MethodPost, "/api/v1/scans", s.apiPostScan},
+		{http.MethodGet, "/api/v1/problems", s.apiGetProblems},
+		{http.MethodPost, "/api/v1/problems/{id}", s.apiPostProblem},
+		{http.MethodPost, "/api/v1/save", s.apiPostSave},
+		{http.MethodGet, "/api/v1/saves", s.apiGetSaves},
+		{http.MethodGet, "/api/v1/saves/{id}", s.apiGetSave},
+		{http.MethodGet, "/api/v1/saves/{id}/diff/{to}", s.apiGetSaveDiff},
+		{http.MethodPost, "/api/v1/import", s.apiPostImport},
+		{http.MethodPost, "/api/v1/merge", s.apiPostMerge},
+		{http.MethodPost, "/api/v1/replicate", s.apiPostReplicate},
+		{http.MethodGet, "/api/v1/replication", s.apiGetReplication},
+		{http.MethodGet, "/api/v1/printcheck", s.apiGetPrintCheck},
+		{http.MethodPost, "/api/v1/printcheck/scans", s.apiPostPrintCheckScan},
+		{http.MethodPost, "/api/v1/printcheck/reset", s.apiPostPrintCheckReset},
+		{http.MethodGet, "/api/v1/export", s.apiGetExport},
+	}
+}
+
+// matchRoute reports whether path matches pattern and returns the values of
+// any {name} segments.
+func matchRoute(pattern string, path string) (routeParams, bool) {
+	patternParts := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	params := make(routeParams)
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if len(pathParts[i]) == 0 {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = pathParts[i]
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// dispatch finds the route for the request and calls its handler. Unknown
+// paths get a 404 and known paths with the wrong method a 405, as JSON for
+// the API and plain text for everything else.
+func (s *Server) dispatch(w http.ResponseWriter, req *http.Request) {
+	pathFound := false
+	allowed := make([]string, 0)
+	for _, r := range s.routes() {
+		params, ok := matchRoute(r.pattern, req.URL.Path)
+		if !ok {
+			continue
+		}
+		pathFound = true
+		if r.method != req.Method && !(r.method == http.MethodGet && req.Method == http.MethodHead) {
+			allowed = append(allowed, r.method)
+			continue
+		}
+		r.handler(w, req, params)
+		return
+	}
+
+	api := isAPIPath(req.URL.Path)
+	if pathFound {
+		w.Header().Set("Allow", strings.Join(allowed, ", "))
+		if api {
+			writeJSONError(w, http.StatusMethodNotAllowed, "method %v not allowed", req.Method)
+		} else {
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+		return
+	}
+	if api {
+		writeJSONError(w, http.StatusNotFound, "no such endpoint: %v", req.URL.Path)
+		return
+	}
+	http.NotFound(w, req)
+}
+
+func isAPIPath(path string) bool {
+	return path == "/api" || strings.HasPrefix(path, "/api/")
+}
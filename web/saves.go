@@ -0,0 +1,188 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/awoodward/azth-scoringcount/export"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// SavesPageData is the data for the save history.
+type SavesPageData struct {
+	Title string
+	Saves []export.SavedExport
+	Error string
+	// Import is the result of an import, shown once after it
+	Import *state.ImportResult
+}
+
+// ImportRequest is the body posted to import a text export.
+type ImportRequest struct {
+	// Source names the file imported, for the edit history.
+	Source string
+	Text   string
+}
+
+// SaveDiffPageData is the data for the differences between two saves.
+type SaveDiffPageData struct {
+	Title   string
+	From    export.SavedExport
+	To      export.SavedExport
+	Changes []export.CarChange
+}
+
+func (s *Server) getSaves(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.renderSaves(w, http.StatusOK, nil, nil)
+}
+
+// renderSaves renders the save history with the result of an import or an
+// error.
+func (s *Server) renderSaves(w http.ResponseWriter, status int, result *state.ImportResult, importErr error) {
+	var pageData SavesPageData
+	pageData.Title = "Saved Exports"
+	pageData.Import = result
+	saves, err := export.Saves()
+	if err != nil {
+		log.Printf("Error listing saves: %v\n", err)
+		importErr = err
+	}
+	if importErr != nil {
+		pageData.Error = importErr.Error()
+	}
+	pageData.Saves = saves
+	w.WriteHeader(status)
+	s.render(w, "saves.html", nil, pageData)
+}
+
+// importText imports a text export into the count.
+func (s *Server) importText(r io.Reader, source string, by state.Editor) (state.ImportResult, error) {
+	cars, err := export.ReadText(r, s.count.Event())
+	if err != nil {
+		return state.ImportResult{}, fmt.Errorf("%v: %v", source, err)
+	}
+	return s.count.ImportStickers(cars, by, source)
+}
+
+// postImport imports an uploaded text export, or the text export of the
+// posted save.
+func (s *Server) postImport(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var r io.Reader
+	var source string
+	if id := req.FormValue("save"); len(id) > 0 {
+		path, err := export.SaveFile(id, id+".txt")
+		if err != nil {
+			s.renderSaves(w, http.StatusNotFound, nil, err)
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			s.renderSaves(w, http.StatusInternalServerError, nil, err)
+			return
+		}
+		defer f.Close()
+		r, source = f, filepath.Base(path)
+	} else {
+		f, header, err := req.FormFile("file")
+		if err != nil {
+			s.renderSaves(w, http.StatusBadRequest, nil, fmt.Errorf("no file to import: %v", err))
+			return
+		}
+		defer f.Close()
+		r, source = f, header.Filename
+	}
+	result, err := s.importText(r, source, editor(req))
+	if err != nil {
+		log.Printf("Error importing %v: %v\n", source, err)
+		s.renderSaves(w, http.StatusBadRequest, nil, err)
+		return
+	}
+	s.renderSaves(w, http.StatusOK, &result, nil)
+}
+
+func (s *Server) getSaveFile(w http.ResponseWriter, req *http.Request, params routeParams) {
+	path, err := export.SaveFile(params["id"], params["file"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Disposition", "attachment; filename="+params["file"])
+	http.ServeFile(w, req, path)
+}
+
+// diffSaves loads two saves and compares them.
+func diffSaves(fromID string, toID string) (SaveDiffPageData, error) {
+	var diff SaveDiffPageData
+	from, err := export.LoadSave(fromID)
+	if err != nil {
+		return diff, err
+	}
+	to, err := export.LoadSave(toID)
+	if err != nil {
+		return diff, err
+	}
+	diff.Title = fmt.Sprintf("Changes from %v to %v", from.ID, to.ID)
+	diff.From = from.SavedExport
+	diff.To = to.SavedExport
+	diff.Changes = export.DiffSaves(from, to)
+	return diff, nil
+}
+
+func (s *Server) getSaveDiff(w http.ResponseWriter, req *http.Request, params routeParams) {
+	pageData, err := diffSaves(req.FormValue("from"), req.FormValue("to"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	s.render(w, "savediff.html", nil, pageData)
+}
+
+func (s *Server) apiGetSaves(w http.ResponseWriter, req *http.Request, params routeParams) {
+	saves, err := export.Saves()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, saves)
+}
+
+func (s *Server) apiGetSave(w http.ResponseWriter, req *http.Request, params routeParams) {
+	save, err := export.LoadSave(params["id"])
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, save)
+}
+
+func (s *Server) apiPostImport(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var body ImportRequest
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid import: %v", err)
+		return
+	}
+	if len(body.Source) == 0 {
+		body.Source = "API"
+	}
+	result, err := s.importText(strings.NewReader(body.Text), body.Source, editor(req))
+	if err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) apiGetSaveDiff(w http.ResponseWriter, req *http.Request, params routeParams) {
+	diff, err := diffSaves(params["id"], params["to"])
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diff.Changes)
+}
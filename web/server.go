@@ -0,0 +1,551 @@
+// Package web serves the counting dashboard, the car edit pages and the
+// JSON API.
+package web
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/config"
+	"github.com/awoodward/azth-scoringcount/export"
+	"github.com/awoodward/azth-scoringcount/replica"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+type CarPageData struct {
+	Title    string
+	Cars     []state.CarData
+	Tally    state.TallyData
+	Scanners []state.ScannerData
+	Problems []state.Problem
+	Alerts   []state.Alert
+	Sessions []state.Session
+	// LastActions holds the last action of each scanner that can be undone.
+	LastActions map[int]*state.Action
+	Replication ReplicationStatus
+	// Commands are the command barcodes that can be scanned from the
+	// screen.
+	Commands []CommandBarcode
+}
+
+// dashboardSessions is the number of counting sessions shown on the
+// dashboard.
+const dashboardSessions = 10
+
+// EditPageData is the data for the car edit page.
+type EditPageData struct {
+	state.Stickers
+	// ClueScans and EmergencyScans count the scans of each sticker. Index
+	// 0 is clue A or emergency 1.
+	ClueScans      [state.ClueNum]state.StickerScan
+	EmergencyScans [state.ClueNum]state.StickerScan
+	Lock           state.CarLock
+	// LastAction is the last change to the car that can be undone, if any.
+	LastAction *state.Action
+	// Original holds the stickers shown when the page was loaded, encoded
+	// by encodeStickers, so a stale submission can be merged.
+	Original string
+	// Conflict is set when a submission was refused because the car had
+	// changed since the page was loaded.
+	Conflict *EditConflict
+	// CarCode is the car's CA barcode, or "" if the event's barcode
+	// layouts cannot show it.
+	CarCode string
+}
+
+// EditConflict lists the sticker changes made by someone else since the
+// edit page was loaded and the changes in the refused submission.
+type EditConflict struct {
+	Theirs []state.StickerChange
+	Yours  []state.StickerChange
+}
+
+// DuplicatePageData is the data for the duplicate scan report.
+type DuplicatePageData struct {
+	Title string
+	Cars  []state.DuplicateCar
+}
+
+// Server is the http.Handler for the web interface.
+type Server struct {
+	// TemplateDir is the directory holding template.html and edit.html.
+	TemplateDir string
+	// Replica forwards changes to the primary station when this is a
+	// secondary station, or is nil.
+	Replica *replica.Forwarder
+
+	count *state.CountData
+}
+
+// New returns a server for count using the templates in templateDir.
+func New(count *state.CountData, templateDir string) *Server {
+	return &Server{TemplateDir: templateDir, count: count}
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/favicon.ico" {
+		// just in case we want to send a favicon at some point
+		http.ServeFile(w, req, "images/favicon.ico")
+		return
+	}
+
+	s.count.Status()
+	log.Printf("Request Path : %v from: %v\n", req.URL.Path, req.RemoteAddr)
+	s.dispatch(w, req)
+}
+
+// parseTemplate loads a template from TemplateDir.
+func (s *Server) parseTemplate(name string, funcs template.FuncMap) (*template.Template, error) {
+	return template.New(name).Funcs(funcs).ParseFiles(filepath.Join(s.TemplateDir, name))
+}
+
+// render executes a template, reporting load errors to the browser.
+func (s *Server) render(w http.ResponseWriter, name string, funcs template.FuncMap, data interface{}) {
+	tmpl, err := s.parseTemplate(name, funcs)
+	if err != nil {
+		log.Printf("Error loading template %v: %v\n", name, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.Execute(w, data); err != nil {
+		log.Printf("Error rendering template %v: %v\n", name, err)
+	}
+}
+
+// save writes the state and text export, logging any error.
+func (s *Server) save() {
+	if _, err := export.Save(s.count); err != nil {
+		log.Println(err)
+	}
+}
+
+// writeExport writes the export in the format named by the format query
+// parameter, the text export if it is empty. It returns false without
+// writing anything if the format is unknown. If download is set the export
+// is sent as a file named for the current time.
+func (s *Server) writeExport(w http.ResponseWriter, req *http.Request, download bool) bool {
+	var contentType, filename string
+	var write func(w http.ResponseWriter) error
+	name := req.URL.Query().Get("format")
+	switch name {
+	case "", config.ExportText:
+		contentType = "text/plain"
+		filename = export.FileName(time.Now())
+		write = func(w http.ResponseWriter) error {
+			return export.WriteTextExport(w, s.count)
+		}
+	case config.ExportCSV:
+		contentType = "text/csv"
+		filename = export.CSVFileName(time.Now())
+		write = func(w http.ResponseWriter) error {
+			return export.WriteCSV(w, s.count)
+		}
+	case config.ExportXLSX:
+		contentType = export.XLSXContentType
+		filename = export.XLSXFileName(time.Now())
+		write = func(w http.ResponseWriter) error {
+			return export.WriteXLSX(w, s.count)
+		}
+	default:
+		format, ok := s.count.Event().ExportFormat(name)
+		if !ok {
+			return false
+		}
+		contentType = mime.TypeByExtension("." + format.FileExtension())
+		if len(contentType) == 0 {
+			contentType = "text/plain"
+		}
+		filename = export.TemplateFileName(time.Now(), format)
+		write = func(w http.ResponseWriter) error {
+			return export.WriteTemplate(w, s.count, format)
+		}
+	}
+	w.Header().Set("Content-Type", contentType)
+	if download {
+		w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	}
+	if err := write(w); err != nil {
+		log.Printf("Error writing export: %v\n", err)
+	}
+	return true
+}
+
+func (s *Server) getDownload(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.save()
+	if !s.writeExport(w, req, true) {
+		http.Error(w, fmt.Sprintf("unknown export format %q", req.URL.Query().Get("format")), http.StatusBadRequest)
+	}
+}
+
+// DownloadFormat is an export format offered on the download page.
+type DownloadFormat struct {
+	Name        string
+	Description string
+}
+
+// DownloadPageData is the data for the download page.
+type DownloadPageData struct {
+	Title   string
+	Formats []DownloadFormat
+}
+
+func (s *Server) getDownloads(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var pageData DownloadPageData
+	pageData.Title = "Downloads"
+	ev := s.count.Event()
+	text := "Text export for the Scoring Program spreadsheet"
+	if _, ok := ev.ExportFormat(config.ExportText); ok {
+		text = "Text export from the event's text template"
+	}
+	pageData.Formats = []DownloadFormat{
+		{config.ExportText, text},
+		{config.ExportCSV, "CSV with a row for every car"},
+		{config.ExportXLSX, "Excel workbook with results, stickers and clue statistics"},
+	}
+	for i, format := range ev.Exports {
+		if format.Name == config.ExportText {
+			continue
+		}
+		pageData.Formats = append(pageData.Formats, DownloadFormat{
+			Name:        format.Name,
+			Description: fmt.Sprintf("Event template, 0-SAVE-%v (.%v)", i+1, format.FileExtension()),
+		})
+	}
+	s.render(w, "downloads.html", nil, pageData)
+}
+
+func (s *Server) getSave(w http.ResponseWriter, req *http.Request, params routeParams) {
+	s.save()
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+// formCar returns the car number from the "car" form value, or 0 if it is
+// missing or out of range.
+func formCar(req *http.Request) int {
+	car, err := strconv.Atoi(req.FormValue("car"))
+	if err != nil || !state.ValidCar(car) {
+		return 0
+	}
+	return car
+}
+
+func (s *Server) getEdit(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car := formCar(req)
+	if car == 0 {
+		http.Redirect(w, req, "/", http.StatusSeeOther)
+		return
+	}
+	stickers := s.count.Stickers(car)
+	s.renderEdit(w, s.editPageData(stickers, stickers))
+}
+
+// editPageData returns the edit page data for stickers, which may differ
+// from the car's stickers when merging. original is the snapshot of the
+// car's stickers the page is based on, with the same version as stickers.
+func (s *Server) editPageData(stickers state.Stickers, original state.Stickers) EditPageData {
+	car := stickers.CarNum
+	var editData EditPageData
+	editData.Stickers = stickers
+	editData.Original = encodeStickers(original)
+	editData.Lock = s.count.CarLock(car)
+	editData.CarCode = s.scanCode(barcode.Scan{Car: car, Kind: barcode.KindCar})
+	if action, ok := s.count.LastAction(0, car); ok {
+		editData.LastAction = &action
+	}
+	for _, scan := range s.count.StickerScans(car) {
+		switch scan.Kind {
+		case barcode.KindClue:
+			editData.ClueScans[scan.Index-1] = scan
+		case barcode.KindEmergency:
+			editData.EmergencyScans[scan.Index-1] = scan
+		}
+	}
+	return editData
+}
+
+func (s *Server) renderEdit(w http.ResponseWriter, editData EditPageData) {
+	funcs := template.FuncMap{
+		"inc": func(i int) int {
+			return i + 1
+		},
+	}
+	s.render(w, "edit.html", funcs, editData)
+}
+
+// encodeStickers encodes the clues then emergencies of a car as a string of
+// 1 for a sticker on the card and 0 for none.
+func encodeStickers(stickers state.Stickers) string {
+	var b strings.Builder
+	for _, present := range stickers.Clues {
+		b.WriteByte("01"[btoi(present)])
+	}
+	for _, present := range stickers.Emergencies {
+		b.WriteByte("01"[btoi(present)])
+	}
+	return b.String()
+}
+
+// decodeStickers decodes stickers encoded by encodeStickers.
+func decodeStickers(s string, car int) (state.Stickers, error) {
+	stickers := state.Stickers{CarNum: car}
+	if len(s) != len(stickers.Clues)+len(stickers.Emergencies) || strings.Trim(s, "01") != "" {
+		return stickers, fmt.Errorf("invalid sticker encoding %q", s)
+	}
+	for i := range stickers.Clues {
+		stickers.Clues[i] = s[i] == '1'
+	}
+	for i := range stickers.Emergencies {
+		stickers.Emergencies[i] = s[len(stickers.Clues)+i] == '1'
+	}
+	return stickers, nil
+}
+
+func btoi(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// parseEditForm builds the stickers for a car from the checkboxes posted by
+// the edit page.
+func parseEditForm(req *http.Request, car int) state.Stickers {
+	var editData state.Stickers
+	editData.CarNum = car
+	for i := 0; i < len(editData.Clues); i++ {
+		val := req.FormValue(fmt.Sprintf("clue%v", i))
+		if len(val) > 0 {
+			editData.Clues[i], _ = strconv.ParseBool(val)
+		}
+	}
+	for i := 0; i < len(editData.Emergencies); i++ {
+		val := req.FormValue(fmt.Sprintf("emergency%v", i))
+		if len(val) > 0 {
+			editData.Emergencies[i], _ = strconv.ParseBool(val)
+		}
+	}
+	editData.Version, _ = strconv.Atoi(req.FormValue("version"))
+	return editData
+}
+
+func (s *Server) postUpdateCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	if car := formCar(req); car != 0 {
+		edited := parseEditForm(req, car)
+		err := s.count.SetStickers(edited, editor(req))
+		if errors.Is(err, state.ErrStale) {
+			log.Printf("Error editing car %v: %v\n", car, err)
+			s.renderConflict(w, req, edited)
+			return
+		}
+		if err != nil {
+			log.Printf("Error editing car %v: %v\n", car, err)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+// renderConflict shows the edit page again for a submission refused because
+// the car changed after the page was loaded. The form is filled with the
+// submitted changes merged into the car's current stickers, so nothing that
+// was scanned in the meantime is lost.
+func (s *Server) renderConflict(w http.ResponseWriter, req *http.Request, edited state.Stickers) {
+	current := s.count.Stickers(edited.CarNum)
+	original, err := decodeStickers(req.FormValue("original"), edited.CarNum)
+	if err != nil {
+		// without the original stickers every difference counts as a change
+		original = current
+	}
+	merged := state.MergeStickers(original, edited, current)
+	editData := s.editPageData(merged, current)
+	editData.Conflict = &EditConflict{
+		Theirs: state.DiffStickers(original, current),
+		Yours:  state.DiffStickers(original, edited),
+	}
+	w.WriteHeader(http.StatusConflict)
+	s.renderEdit(w, editData)
+}
+
+func (s *Server) getClearCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	if car := formCar(req); car != 0 {
+		if err := s.count.ClearCar(car); err != nil {
+			log.Printf("Error clearing car %v: %v\n", car, err)
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+	}
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+// editor identifies the scorer making a request by the name given in the
+// "by" form value, or else the basic auth user, and the remote address.
+func editor(req *http.Request) state.Editor {
+	user := strings.TrimSpace(req.FormValue("by"))
+	if len(user) == 0 {
+		user, _, _ = req.BasicAuth()
+	}
+	return state.Editor{User: user, RemoteAddr: req.RemoteAddr}
+}
+
+func (s *Server) postLockCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	if car := formCar(req); car != 0 {
+		if err := s.count.Lock(car, editor(req).String()); err != nil {
+			log.Println(err)
+		}
+	}
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+func (s *Server) postUnlockCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	car := formCar(req)
+	if car != 0 {
+		if err := s.count.Unlock(car, editor(req).String()); err != nil {
+			log.Println(err)
+		}
+	}
+	http.Redirect(w, req, fmt.Sprintf("/edit?car=%v", car), http.StatusSeeOther)
+}
+
+func (s *Server) getDashboard(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var carData CarPageData
+	carData.Cars = s.count.BuildCarData()
+	carData.Title = "Cars!"
+	carData.Tally = s.count.Tally()
+	carData.Scanners = s.count.Scanners()
+	carData.LastActions = make(map[int]*state.Action)
+	for _, scanner := range carData.Scanners {
+		if action, ok := s.count.LastAction(scanner.ScannerNum, 0); ok {
+			carData.LastActions[scanner.ScannerNum] = &action
+		}
+	}
+	carData.Problems = s.count.Problems()
+	carData.Alerts = s.count.Alerts()
+	carData.Sessions = s.count.Sessions()
+	carData.Replication = s.replicationStatus()
+	carData.Commands = s.commandBarcodes()
+	if len(carData.Sessions) > dashboardSessions {
+		carData.Sessions = carData.Sessions[:dashboardSessions]
+	}
+	sortOrder := req.URL.Query().Get("sort")
+	switch sortOrder {
+	case "leader":
+		// Sort data by score
+		sort.SliceStable(carData.Cars, func(i, j int) bool {
+			if (carData.Cars[i].Scanned != carData.Cars[j].Scanned) && !carData.Cars[j].Scanned {
+				return true
+			}
+			return carData.Cars[i].Scanned && carData.Cars[j].Scanned && carData.Cars[j].Score < carData.Cars[i].Score
+		})
+	}
+
+	//log.Printf("Sort: %v\n", sortOrder)
+	s.render(w, "template.html", nil, carData)
+}
+
+func (s *Server) getDuplicates(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var pageData DuplicatePageData
+	pageData.Title = "Duplicate Scans"
+	pageData.Cars = s.count.DuplicateReport()
+	s.render(w, "duplicates.html", nil, pageData)
+}
+
+func (s *Server) postAckAlert(w http.ResponseWriter, req *http.Request, params routeParams) {
+	id, _ := strconv.Atoi(req.FormValue("id"))
+	if err := s.count.AcknowledgeAlert(id); err != nil {
+		log.Println(err)
+	}
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+func (s *Server) postCloseSession(w http.ResponseWriter, req *http.Request, params routeParams) {
+	scanner, err := strconv.Atoi(req.FormValue("scanner"))
+	if err == nil {
+		s.count.CloseSession(scanner)
+	}
+	http.Redirect(w, req, "/", http.StatusSeeOther)
+}
+
+// AuditPageData is the data for a car's edit history.
+type AuditPageData struct {
+	Title string
+	Car   state.CarData
+	Edits []state.Edit
+}
+
+func (s *Server) getAudit(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car := formCar(req)
+	if car == 0 {
+		http.Redirect(w, req, "/", http.StatusSeeOther)
+		return
+	}
+	var pageData AuditPageData
+	pageData.Title = fmt.Sprintf("Car %v Edit History", car)
+	pageData.Car = s.count.Car(car)
+	pageData.Edits = s.count.CarEdits(car)
+	s.render(w, "audit.html", nil, pageData)
+}
+
+// CarDetailPageData is the data for the read-only page of one car.
+type CarDetailPageData struct {
+	Title    string
+	Car      state.CarData
+	Times    state.CarTime
+	Timeline []state.TimelineEntry
+	// CarCode is the car's CA barcode, or "" if the event's barcode
+	// layouts cannot show it.
+	CarCode string
+}
+
+func (s *Server) getCar(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car := formCar(req)
+	if car == 0 {
+		http.Redirect(w, req, "/", http.StatusSeeOther)
+		return
+	}
+	var pageData CarDetailPageData
+	pageData.Title = fmt.Sprintf("Car %v", car)
+	pageData.Car = s.count.Car(car)
+	pageData.Times = s.count.CarTimes()[car]
+	pageData.Timeline = s.count.CarTimeline(car)
+	pageData.CarCode = s.scanCode(barcode.Scan{Car: car, Kind: barcode.KindCar})
+	s.render(w, "car.html", nil, pageData)
+}
+
+// postUndo undoes the last change to the posted car, or else the last
+// action of the posted scanner.
+func (s *Server) postUndo(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	var err error
+	redirect := "/"
+	if car := formCar(req); car != 0 {
+		_, err = s.count.UndoCar(car)
+		redirect = fmt.Sprintf("/edit?car=%v", car)
+	} else {
+		scanner, convErr := strconv.Atoi(req.FormValue("scanner"))
+		if convErr != nil {
+			http.Error(w, "no car or scanner to undo", http.StatusBadRequest)
+			return
+		}
+		_, err = s.count.UndoScanner(scanner)
+	}
+	if err != nil {
+		log.Printf("Error undoing: %v\n", err)
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	http.Redirect(w, req, redirect, http.StatusSeeOther)
+}
@@ -0,0 +1,139 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/awoodward/azth-scoringcount/barcode"
+	"github.com/awoodward/azth-scoringcount/state"
+)
+
+// Verification actions
+const (
+	actionAccept    = "accept"
+	actionReconcile = "reconcile"
+	actionClear     = "clear"
+)
+
+// VerifyResolution is the body posted to settle a car's second count.
+// Count ("primary" or "second") is used to accept a count; Kind ("CL" or
+// "EM"), Sticker ("A" or "3") and Present to reconcile one sticker.
+type VerifyResolution struct {
+	Action  string
+	Count   string
+	Kind    string
+	Sticker string
+	Present bool
+}
+
+// VerifyPageData is the data for the verification report.
+type VerifyPageData struct {
+	Title    string
+	Cars     []state.VerifyCar
+	Scanners []state.ScannerData
+}
+
+// resolveVerify accepts a count, reconciles a sticker or clears the second
+// count of a car.
+func (s *Server) resolveVerify(car int, res VerifyResolution, by state.Editor) error {
+	switch res.Action {
+	case actionAccept:
+		return s.count.AcceptCount(car, res.Count, by)
+	case actionReconcile:
+		code := fmt.Sprintf("%v-%v-%v", car, strings.ToUpper(res.Kind), strings.TrimSpace(res.Sticker))
+		scan, err := barcode.Parse(code, s.count.Event().Limits())
+		if err != nil {
+			return err
+		}
+		return s.count.ReconcileSticker(car, scan.Kind, scan.Index, res.Present, by)
+	case actionClear:
+		s.count.ClearVerification(car)
+		return nil
+	}
+	return fmt.Errorf("unknown action %q", res.Action)
+}
+
+func (s *Server) getVerify(w http.ResponseWriter, req *http.Request, params routeParams) {
+	var pageData VerifyPageData
+	pageData.Title = "Second Counts"
+	pageData.Cars = s.count.VerifyReport()
+	pageData.Scanners = s.count.Scanners()
+	s.render(w, "verify.html", nil, pageData)
+}
+
+func (s *Server) postVerify(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	car := formCar(req)
+	present, _ := strconv.ParseBool(req.FormValue("present"))
+	res := VerifyResolution{
+		Action:  req.FormValue("action"),
+		Count:   req.FormValue("count"),
+		Kind:    req.FormValue("kind"),
+		Sticker: req.FormValue("sticker"),
+		Present: present,
+	}
+	if err := s.resolveVerify(car, res, editor(req)); err != nil {
+		log.Printf("Error verifying car %v: %v\n", car, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Redirect(w, req, fmt.Sprintf("/verify#car%v", car), http.StatusSeeOther)
+}
+
+func (s *Server) postVerifyScanner(w http.ResponseWriter, req *http.Request, params routeParams) {
+	req.ParseForm()
+	scanner, err := strconv.Atoi(req.FormValue("scanner"))
+	if err == nil {
+		on, _ := strconv.ParseBool(req.FormValue("on"))
+		s.count.SetVerifying(scanner, on)
+	}
+	http.Redirect(w, req, "/verify", http.StatusSeeOther)
+}
+
+func (s *Server) apiGetVerification(w http.ResponseWriter, req *http.Request, params routeParams) {
+	writeJSON(w, http.StatusOK, s.count.VerifyReport())
+}
+
+func (s *Server) apiGetCarVerification(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.CarVerification(car))
+}
+
+func (s *Server) apiPostCarVerification(w http.ResponseWriter, req *http.Request, params routeParams) {
+	car, ok := apiCar(w, params)
+	if !ok {
+		return
+	}
+	var res VerifyResolution
+	if err := json.NewDecoder(req.Body).Decode(&res); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid resolution: %v", err)
+		return
+	}
+	if err := s.resolveVerify(car, res, editor(req)); err != nil {
+		writeJSONError(w, http.StatusUnprocessableEntity, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.count.CarVerification(car))
+}
+
+func (s *Server) apiPutScannerVerifying(w http.ResponseWriter, req *http.Request, params routeParams) {
+	scanner, err := strconv.Atoi(params["scanner"])
+	if err != nil || scanner < 0 || scanner >= state.ScannerMax {
+		writeJSONError(w, http.StatusBadRequest, "invalid scanner %q", params["scanner"])
+		return
+	}
+	var body struct{ Verifying bool }
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid body: %v", err)
+		return
+	}
+	s.count.SetVerifying(scanner, body.Verifying)
+	writeJSON(w, http.StatusOK, s.count.Scanners())
+}